@@ -0,0 +1,347 @@
+// ベクタと配列を扱う組み込み関数
+package eval
+
+import (
+	"fmt"
+
+	"github.com/koplec/gospl/internal/types"
+)
+
+func registerArrayBuiltins(env *Environment) {
+	env.define("make-array", &BuiltinFunc{Name: "make-array", Fn: builtinMakeArray})
+	env.define("vector", &BuiltinFunc{Name: "vector", Fn: builtinVector})
+	env.define("aref", &BuiltinFunc{Name: "aref", Fn: builtinAref})
+	env.define("arrayp", &BuiltinFunc{Name: "arrayp", Fn: builtinArrayp})
+	env.define("vectorp", &BuiltinFunc{Name: "vectorp", Fn: builtinVectorp})
+	env.define("array-rank", &BuiltinFunc{Name: "array-rank", Fn: builtinArrayRank})
+	env.define("array-dimensions", &BuiltinFunc{Name: "array-dimensions", Fn: builtinArrayDimensions})
+	env.define("array-dimension", &BuiltinFunc{Name: "array-dimension", Fn: builtinArrayDimension})
+	env.define("array-total-size", &BuiltinFunc{Name: "array-total-size", Fn: builtinArrayTotalSize})
+	env.define("adjustable-array-p", &BuiltinFunc{Name: "adjustable-array-p", Fn: builtinAdjustableArrayP})
+	env.define("array-has-fill-pointer-p", &BuiltinFunc{Name: "array-has-fill-pointer-p", Fn: builtinArrayHasFillPointerP})
+	env.define("fill-pointer", &BuiltinFunc{Name: "fill-pointer", Fn: builtinFillPointer})
+	env.define("vector-push", &BuiltinFunc{Name: "vector-push", Fn: builtinVectorPush})
+	env.define("vector-push-extend", &BuiltinFunc{Name: "vector-push-extend", Fn: builtinVectorPushExtend})
+	env.define("vector-pop", &BuiltinFunc{Name: "vector-pop", Fn: builtinVectorPop})
+	env.define("length", &BuiltinFunc{Name: "length", Fn: builtinLength})
+}
+
+// (make-array dimensions &key initial-element initial-contents adjustable fill-pointer element-type)
+// dimensionsは整数1つか整数のリスト
+// fill-pointerはTなら大きさと同じ位置、整数ならその位置
+// element-typeは受け付けるだけで使わない、どの型の要素も入れられる
+func builtinMakeArray(args []types.Expr) (types.Expr, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("make-array requires at least 1 argument")
+	}
+	dims, err := arrayDimensions(args[0])
+	if err != nil {
+		return nil, err
+	}
+	keys, err := keywordArgs("make-array", args[1:],
+		"initial-element", "initial-contents", "adjustable", "fill-pointer", "element-type")
+	if err != nil {
+		return nil, err
+	}
+
+	var array *types.Array
+	if contents, ok := keys["initial-contents"]; ok {
+		if _, ok := keys["initial-element"]; ok {
+			return nil, fmt.Errorf("make-array: cannot use both :initial-element and :initial-contents")
+		}
+		if array, err = types.NewArrayFromContents(len(dims), contents); err != nil {
+			return nil, fmt.Errorf("make-array: %w", err)
+		}
+		for i, d := range dims {
+			if array.Dims[i] != d {
+				return nil, fmt.Errorf("make-array: :initial-contents do not match dimensions %v", dims)
+			}
+			if d == 0 {
+				// 大きさ0の次元より後ろは中身から決まらないので、dimensionsに合わせる
+				array.Dims = dims
+				break
+			}
+		}
+	} else {
+		var initial types.Expr = &types.Nil{}
+		if elem, ok := keys["initial-element"]; ok {
+			initial = elem
+		}
+		if array, err = types.NewArray(dims, initial); err != nil {
+			return nil, fmt.Errorf("make-array: %w", err)
+		}
+	}
+
+	if adjustable, ok := keys["adjustable"]; ok {
+		array.Adjustable = isTrue(adjustable)
+	}
+	if fp, ok := keys["fill-pointer"]; ok && isTrue(fp) {
+		if !array.IsVector() {
+			return nil, fmt.Errorf("make-array: only vectors can have a fill pointer")
+		}
+		array.FillPointer = dims[0]
+		if n, ok := fp.(types.Fixnum); ok {
+			if n.Value < 0 || n.Value > int64(dims[0]) {
+				return nil, fmt.Errorf("make-array: fill pointer %d out of range", n.Value)
+			}
+			array.FillPointer = int(n.Value)
+		}
+	}
+	return array, nil
+}
+
+// (vector &rest objects)
+func builtinVector(args []types.Expr) (types.Expr, error) {
+	return types.NewVector(append([]types.Expr(nil), args...)), nil
+}
+
+// (aref array &rest subscripts)
+// フィルポインタより後ろの要素も読める
+func builtinAref(args []types.Expr) (types.Expr, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("aref requires at least 1 argument")
+	}
+	array, index, err := arrayIndex("aref", args[0], args[1:])
+	if err != nil {
+		return nil, err
+	}
+	return array.Elements[index], nil
+}
+
+// (arrayp x)
+func builtinArrayp(args []types.Expr) (types.Expr, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("arrayp requires exactly 1 argument")
+	}
+	_, ok := args[0].(*types.Array)
+	return types.Boolean{Value: ok}, nil
+}
+
+// (vectorp x)
+func builtinVectorp(args []types.Expr) (types.Expr, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("vectorp requires exactly 1 argument")
+	}
+	array, ok := args[0].(*types.Array)
+	return types.Boolean{Value: ok && array.IsVector()}, nil
+}
+
+// (array-rank array)
+func builtinArrayRank(args []types.Expr) (types.Expr, error) {
+	array, err := arrayArg("array-rank", args)
+	if err != nil {
+		return nil, err
+	}
+	return types.Fixnum{Value: int64(array.Rank())}, nil
+}
+
+// (array-dimensions array)
+func builtinArrayDimensions(args []types.Expr) (types.Expr, error) {
+	array, err := arrayArg("array-dimensions", args)
+	if err != nil {
+		return nil, err
+	}
+	dims := make([]types.Expr, len(array.Dims))
+	for i, d := range array.Dims {
+		dims[i] = types.Fixnum{Value: int64(d)}
+	}
+	return sliceToList(dims), nil
+}
+
+// (array-dimension array axis)
+func builtinArrayDimension(args []types.Expr) (types.Expr, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("array-dimension requires exactly 2 arguments")
+	}
+	array, err := arrayArg("array-dimension", args[:1])
+	if err != nil {
+		return nil, err
+	}
+	axis, ok := args[1].(types.Fixnum)
+	if !ok || axis.Value < 0 || axis.Value >= int64(array.Rank()) {
+		return nil, fmt.Errorf("array-dimension: invalid axis %v for a rank %d array", args[1], array.Rank())
+	}
+	return types.Fixnum{Value: int64(array.Dims[axis.Value])}, nil
+}
+
+// (array-total-size array)
+func builtinArrayTotalSize(args []types.Expr) (types.Expr, error) {
+	array, err := arrayArg("array-total-size", args)
+	if err != nil {
+		return nil, err
+	}
+	return types.Fixnum{Value: int64(len(array.Elements))}, nil
+}
+
+// (adjustable-array-p array)
+func builtinAdjustableArrayP(args []types.Expr) (types.Expr, error) {
+	array, err := arrayArg("adjustable-array-p", args)
+	if err != nil {
+		return nil, err
+	}
+	return types.Boolean{Value: array.Adjustable}, nil
+}
+
+// (array-has-fill-pointer-p array)
+func builtinArrayHasFillPointerP(args []types.Expr) (types.Expr, error) {
+	array, err := arrayArg("array-has-fill-pointer-p", args)
+	if err != nil {
+		return nil, err
+	}
+	return types.Boolean{Value: array.FillPointer >= 0}, nil
+}
+
+// (fill-pointer vector)
+func builtinFillPointer(args []types.Expr) (types.Expr, error) {
+	vector, err := fillPointerArg("fill-pointer", args)
+	if err != nil {
+		return nil, err
+	}
+	return types.Fixnum{Value: int64(vector.FillPointer)}, nil
+}
+
+// (vector-push new-element vector)
+// 空きがあれば要素を足して、その位置を返す
+// 空きがなければ何もせずにNIL
+func builtinVectorPush(args []types.Expr) (types.Expr, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("vector-push requires exactly 2 arguments")
+	}
+	vector, err := fillPointerArg("vector-push", args[1:])
+	if err != nil {
+		return nil, err
+	}
+	if vector.FillPointer >= len(vector.Elements) {
+		return &types.Nil{}, nil
+	}
+	index := vector.FillPointer
+	vector.Push(args[0])
+	return types.Fixnum{Value: int64(index)}, nil
+}
+
+// (vector-push-extend new-element vector &optional extension)
+// 空きがなければベクタを大きくしてから要素を足し、その位置を返す
+// 大きくできるのは伸縮可能なベクタだけ
+// extensionはGoのappendに任せるので使わない
+func builtinVectorPushExtend(args []types.Expr) (types.Expr, error) {
+	if len(args) < 2 || len(args) > 3 {
+		return nil, fmt.Errorf("vector-push-extend requires 2 or 3 arguments")
+	}
+	vector, err := fillPointerArg("vector-push-extend", args[1:2])
+	if err != nil {
+		return nil, err
+	}
+	if vector.FillPointer >= len(vector.Elements) && !vector.Adjustable {
+		return nil, fmt.Errorf("vector-push-extend: %v is full and not adjustable", vector)
+	}
+	if vector.FillPointer >= types.ArrayTotalSizeLimit {
+		return nil, fmt.Errorf("vector-push-extend: vector exceeds the total size limit %d", types.ArrayTotalSizeLimit)
+	}
+	index := vector.FillPointer
+	vector.Push(args[0])
+	return types.Fixnum{Value: int64(index)}, nil
+}
+
+// (vector-pop vector)
+// フィルポインタを1つ戻して、そこにあった要素を返す
+func builtinVectorPop(args []types.Expr) (types.Expr, error) {
+	vector, err := fillPointerArg("vector-pop", args)
+	if err != nil {
+		return nil, err
+	}
+	if vector.FillPointer == 0 {
+		return nil, fmt.Errorf("vector-pop: %v is empty", vector)
+	}
+	vector.FillPointer--
+	return vector.Elements[vector.FillPointer], nil
+}
+
+// (length sequence)
+// リスト、文字列、ベクタの長さ
+// フィルポインタのあるベクタは、フィルポインタまでの長さ
+func builtinLength(args []types.Expr) (types.Expr, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("length requires exactly 1 argument")
+	}
+	switch seq := args[0].(type) {
+	case types.String:
+		return types.Fixnum{Value: int64(len([]rune(seq.Value)))}, nil
+	case *types.Array:
+		if seq.IsVector() {
+			return types.Fixnum{Value: int64(len(seq.Active()))}, nil
+		}
+	case *types.Nil, *types.Cons:
+		elems, err := listToSlice(seq)
+		if err != nil {
+			return nil, fmt.Errorf("length: %w", err)
+		}
+		return types.Fixnum{Value: int64(len(elems))}, nil
+	}
+	return nil, fmt.Errorf("length: not a sequence: %v", args[0])
+}
+
+// make-arrayの大きさの引数
+// 3のような整数1つか、(2 3)のような整数のリスト
+func arrayDimensions(expr types.Expr) ([]int, error) {
+	if n, ok := expr.(types.Fixnum); ok {
+		expr = &types.Cons{Car: n, Cdr: &types.Nil{}}
+	}
+	elems, err := listToSlice(expr)
+	if err != nil {
+		return nil, fmt.Errorf("make-array: invalid dimensions: %v", expr)
+	}
+	dims := make([]int, len(elems))
+	for i, elem := range elems {
+		n, ok := elem.(types.Fixnum)
+		if !ok || n.Value < 0 {
+			return nil, fmt.Errorf("make-array: invalid dimension: %v", elem)
+		}
+		dims[i] = int(n.Value)
+	}
+	return dims, nil
+}
+
+// 配列と添字の引数を、配列と行優先の要素の位置にする
+func arrayIndex(fn string, arrayExpr types.Expr, subscriptExprs []types.Expr) (*types.Array, int, error) {
+	array, ok := arrayExpr.(*types.Array)
+	if !ok {
+		return nil, 0, fmt.Errorf("%s: not an array: %v", fn, arrayExpr)
+	}
+	subscripts := make([]int, len(subscriptExprs))
+	for i, s := range subscriptExprs {
+		n, ok := s.(types.Fixnum)
+		if !ok {
+			return nil, 0, fmt.Errorf("%s: subscript must be an integer, got %v", fn, s)
+		}
+		subscripts[i] = int(n.Value)
+	}
+	index, err := array.RowMajorIndex(subscripts)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", fn, err)
+	}
+	return array, index, nil
+}
+
+// 引数が配列1つであることを確認して返す
+func arrayArg(fn string, args []types.Expr) (*types.Array, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("%s requires exactly 1 argument", fn)
+	}
+	array, ok := args[0].(*types.Array)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an array: %v", fn, args[0])
+	}
+	return array, nil
+}
+
+// 引数がフィルポインタのあるベクタ1つであることを確認して返す
+func fillPointerArg(fn string, args []types.Expr) (*types.Array, error) {
+	array, err := arrayArg(fn, args)
+	if err != nil {
+		return nil, err
+	}
+	if array.FillPointer < 0 {
+		return nil, fmt.Errorf("%s: %v does not have a fill pointer", fn, array)
+	}
+	return array, nil
+}
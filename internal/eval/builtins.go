@@ -3,6 +3,7 @@ package eval
 
 import (
 	"fmt"
+	"slices"
 
 	"github.com/koplec/gospl/internal/types"
 )
@@ -15,32 +16,29 @@ type BuiltinFunc struct {
 }
 
 // 組み込み関数を呼び出し
-func (b BuiltinFunc) Call(args []types.Expr) (types.Expr, error) {
+func (b *BuiltinFunc) Call(args []types.Expr) (types.Expr, error) {
 	return b.Fn(args)
 }
 
-func (b BuiltinFunc) String() string {
+func (b *BuiltinFunc) String() string {
 	return fmt.Sprintf("#<BUILTIN %s>", b.Name)
 }
 
 // 加算
+// 整数どうしはあふれるとbignumになり、浮動小数点数が混ざると浮動小数点数になる
 // func([]types.Expr) (types.Expr, error)という関数系自体を型として定義できたらかっこいいかもと思ったり
 // 関数定義時に型を明示することもできる。var buitinAdd BuiltinFn = func(arg []types.Expr, error){
 func builtinAdd(args []types.Expr) (types.Expr, error) {
-	if len(args) == 0 {
-		return types.Number{Value: 0}, nil
-	}
-
-	var sum float64
+	var sum types.Number = types.Fixnum{Value: 0}
 	for _, arg := range args {
-		num, ok := arg.(types.Number)
-		if !ok {
-			return nil, fmt.Errorf("+ expects numbers, got %T", arg)
+		num, err := numberArg("+", arg)
+		if err != nil {
+			return nil, err
 		}
-		sum += num.Value
+		sum = types.Add(sum, num)
 	}
 
-	return types.Number{Value: sum}, nil
+	return sum, nil
 }
 
 // 下のように型アサーションを使うほうほうもあるけど、冗長
@@ -53,78 +51,106 @@ func builtinSub(args []types.Expr) (types.Expr, error) {
 		return nil, fmt.Errorf("- requires at least 1 argument")
 	}
 
-	first, ok := args[0].(types.Number)
-	if !ok {
-		return nil, fmt.Errorf("- expects numbers, got %T", args[0])
+	first, err := numberArg("-", args[0])
+	if err != nil {
+		return nil, err
 	}
 
 	if len(args) == 1 {
 		//単項マイナス (- 3) -> -3
-		return types.Number{Value: -first.Value}, nil
+		return types.Negate(first), nil
 	}
 
-	result := first.Value
+	result := first
 	for _, arg := range args[1:] {
-		num, ok := arg.(types.Number)
-		if !ok {
-			return nil, fmt.Errorf("- expects numbers, got %T", num)
+		num, err := numberArg("-", arg)
+		if err != nil {
+			return nil, err
 		}
-		result -= num.Value
+		result = types.Sub(result, num)
 	}
 
-	return types.Number{Value: result}, nil
+	return result, nil
 }
 
 // 乗算
 func builtinMul(args []types.Expr) (types.Expr, error) {
-	if len(args) == 0 {
-		return types.Number{Value: 1.0}, nil
-	}
-
-	result := 1.0
+	var result types.Number = types.Fixnum{Value: 1}
 	for _, arg := range args {
-		num, ok := arg.(types.Number)
-		if !ok {
-			return nil, fmt.Errorf("* expects numbers, got %T", num)
+		num, err := numberArg("*", arg)
+		if err != nil {
+			return nil, err
 		}
-		result *= num.Value
+		result = types.Mul(result, num)
 	}
 
-	return types.Number{Value: result}, nil
+	return result, nil
 }
 
 // 除算
+// 整数どうしで割り切れないときは分数になる (/ 1 3) -> 1/3
 func builtinDiv(args []types.Expr) (types.Expr, error) {
 	if len(args) == 0 {
 		return nil, fmt.Errorf("/ requires at least 1 argument")
 	}
 
-	first, ok := args[0].(types.Number)
-	if !ok {
-		return nil, fmt.Errorf("/ expects numbers, got %T", args[0])
+	first, err := numberArg("/", args[0])
+	if err != nil {
+		return nil, err
 	}
 
 	if len(args) == 1 {
 		// 逆数
-		if first.Value == 0 {
-			return nil, fmt.Errorf("division by zero")
-		}
-		return types.Number{Value: 1.0 / first.Value}, nil
+		return types.Div(types.Fixnum{Value: 1}, first)
 	}
 
-	result := first.Value
+	result := first
 	for _, arg := range args[1:] {
-		num, ok := arg.(types.Number)
-		if !ok {
-			return nil, fmt.Errorf("/ expects numbers, got %T", arg)
+		num, err := numberArg("/", arg)
+		if err != nil {
+			return nil, err
 		}
-		if num.Value == 0 {
-			return nil, fmt.Errorf("division by zero")
+		if result, err = types.Div(result, num); err != nil {
+			return nil, err
 		}
-		result /= num.Value
 	}
 
-	return types.Number{Value: result}, nil
+	return result, nil
+}
+
+// (= a b ...) (< a b ...) など、数値の比較
+// 隣り合う引数がすべてokを満たせばT
+// 型の違う数値も値で比べるので、(= 1 1.0)はT
+// NaNとはどう比べてもNIL
+func numberComparison(name string, ok func(cmp int) bool) BuiltinFn {
+	return func(args []types.Expr) (types.Expr, error) {
+		if len(args) == 0 {
+			return nil, fmt.Errorf("%s requires at least 1 argument", name)
+		}
+		nums := make([]types.Number, len(args))
+		for i, arg := range args {
+			num, err := numberArg(name, arg)
+			if err != nil {
+				return nil, err
+			}
+			nums[i] = num
+		}
+		for i := 1; i < len(nums); i++ {
+			if c, ordered := types.Compare(nums[i-1], nums[i]); !ordered || !ok(c) {
+				return types.Boolean{Value: false}, nil
+			}
+		}
+		return types.Boolean{Value: true}, nil
+	}
+}
+
+// 引数が数値であることを確認する
+func numberArg(fn string, arg types.Expr) (types.Number, error) {
+	num, ok := arg.(types.Number)
+	if !ok {
+		return nil, fmt.Errorf("%s expects numbers, got %T", fn, arg)
+	}
+	return num, nil
 }
 
 func builtinFuncall(args []types.Expr) (types.Expr, error) {
@@ -181,3 +207,35 @@ func listToSlice(expr types.Expr) ([]types.Expr, error) {
 	return result, nil
 
 }
+
+// sliceをリストにする
+func sliceToList(elems []types.Expr) types.Expr {
+	var list types.Expr = &types.Nil{}
+	for i := len(elems) - 1; i >= 0; i-- {
+		list = &types.Cons{Car: elems[i], Cdr: list}
+	}
+	return list
+}
+
+// :key valueの形のキーワード引数を、キーワードの名前から値を引く表にする
+// allowedにない名前や、値のないキーワードはエラー
+// 同じキーワードが2回あるときは、Common Lispと同じく最初のものを使う
+func keywordArgs(fn string, args []types.Expr, allowed ...string) (map[string]types.Expr, error) {
+	if len(args)%2 != 0 {
+		return nil, fmt.Errorf("%s: odd number of keyword arguments", fn)
+	}
+	keys := make(map[string]types.Expr)
+	for i := 0; i < len(args); i += 2 {
+		key, ok := args[i].(*types.Symbol)
+		if !ok || !key.IsKeyword() {
+			return nil, fmt.Errorf("%s: not a keyword: %v", fn, args[i])
+		}
+		if !slices.Contains(allowed, key.Name) {
+			return nil, fmt.Errorf("%s: unknown keyword %s", fn, key)
+		}
+		if _, ok := keys[key.Name]; !ok {
+			keys[key.Name] = args[i+1]
+		}
+	}
+	return keys, nil
+}
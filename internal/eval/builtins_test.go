@@ -12,22 +12,22 @@ func TestBuiltinAdd(t *testing.T) {
 		args []types.Expr
 		want types.Number
 	}{
-		{"noargs", []types.Expr{}, types.Number{Value: 0}},
+		{"noargs", []types.Expr{}, types.Fixnum{Value: 0}},
 		{
 			"single",
 			[]types.Expr{
-				types.Number{Value: 5},
+				types.Fixnum{Value: 5},
 			},
-			types.Number{Value: 5},
+			types.Fixnum{Value: 5},
 		},
 		{
 			"multiple",
 			[]types.Expr{
-				types.Number{Value: 1},
-				types.Number{Value: 2},
-				types.Number{Value: 3},
+				types.Fixnum{Value: 1},
+				types.Fixnum{Value: 2},
+				types.Fixnum{Value: 3},
 			},
-			types.Number{Value: 6},
+			types.Fixnum{Value: 6},
 		},
 	}
 
@@ -37,12 +37,11 @@ func TestBuiltinAdd(t *testing.T) {
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
-			num, ok := result.(types.Number)
-			if !ok {
-				t.Fatalf("unexpected type: %T", num)
+			if _, ok := result.(types.Number); !ok {
+				t.Fatalf("unexpected type: %T", result)
 			}
-			if num.Value != tt.want.Value {
-				t.Errorf("got %v, want %v", num.Value, tt.want.Value)
+			if result.String() != tt.want.String() {
+				t.Errorf("got %v, want %v", result, tt.want)
 			}
 		})
 	}
@@ -50,7 +49,7 @@ func TestBuiltinAdd(t *testing.T) {
 
 func TestBuiltinAdd_TypeError(t *testing.T) {
 	result, err := builtinAdd([]types.Expr{
-		types.Number{Value: 1},
+		types.Fixnum{Value: 1},
 		types.String{Value: "hello"},
 	})
 	if err == nil {
@@ -70,18 +69,18 @@ func TestBuiltinSub(t *testing.T) {
 		{
 			"single",
 			[]types.Expr{
-				types.Number{Value: 5},
+				types.Fixnum{Value: 5},
 			},
-			types.Number{Value: -5},
+			types.Fixnum{Value: -5},
 		},
 		{
 			"multiple",
 			[]types.Expr{
-				types.Number{Value: 1},
-				types.Number{Value: 2},
-				types.Number{Value: 3},
+				types.Fixnum{Value: 1},
+				types.Fixnum{Value: 2},
+				types.Fixnum{Value: 3},
 			},
-			types.Number{Value: -4},
+			types.Fixnum{Value: -4},
 		},
 	}
 
@@ -91,12 +90,11 @@ func TestBuiltinSub(t *testing.T) {
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
-			num, ok := result.(types.Number)
-			if !ok {
-				t.Fatalf("unexpected type: %T", num)
+			if _, ok := result.(types.Number); !ok {
+				t.Fatalf("unexpected type: %T", result)
 			}
-			if num.Value != tt.want.Value {
-				t.Errorf("got %v, want %v", num.Value, tt.want.Value)
+			if result.String() != tt.want.String() {
+				t.Errorf("got %v, want %v", result, tt.want)
 			}
 		})
 	}
@@ -115,22 +113,22 @@ func TestBuiltinMul(t *testing.T) {
 		args []types.Expr
 		want types.Number
 	}{
-		{"noargs", []types.Expr{}, types.Number{Value: 1}},
+		{"noargs", []types.Expr{}, types.Fixnum{Value: 1}},
 		{
 			"single",
 			[]types.Expr{
-				types.Number{Value: 5},
+				types.Fixnum{Value: 5},
 			},
-			types.Number{Value: 5},
+			types.Fixnum{Value: 5},
 		},
 		{
 			"multiple",
 			[]types.Expr{
-				types.Number{Value: 1},
-				types.Number{Value: 2},
-				types.Number{Value: 5},
+				types.Fixnum{Value: 1},
+				types.Fixnum{Value: 2},
+				types.Fixnum{Value: 5},
 			},
-			types.Number{Value: 10},
+			types.Fixnum{Value: 10},
 		},
 	}
 
@@ -140,12 +138,11 @@ func TestBuiltinMul(t *testing.T) {
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
-			num, ok := result.(types.Number)
-			if !ok {
-				t.Fatalf("unexpected type: %T", num)
+			if _, ok := result.(types.Number); !ok {
+				t.Fatalf("unexpected type: %T", result)
 			}
-			if num.Value != tt.want.Value {
-				t.Errorf("got %v, want %v", num.Value, tt.want.Value)
+			if result.String() != tt.want.String() {
+				t.Errorf("got %v, want %v", result, tt.want)
 			}
 		})
 	}
@@ -160,18 +157,18 @@ func TestBuiltinDiv(t *testing.T) {
 		{
 			"single",
 			[]types.Expr{
-				types.Number{Value: 5},
+				types.Fixnum{Value: 5},
 			},
-			types.Number{Value: 1.0 / 5.0},
+			mustRatio(1, 5),
 		},
 		{
 			"multiple",
 			[]types.Expr{
-				types.Number{Value: 1},
-				types.Number{Value: 2},
-				types.Number{Value: 5},
+				types.Fixnum{Value: 1},
+				types.Fixnum{Value: 2},
+				types.Fixnum{Value: 5},
 			},
-			types.Number{Value: 0.1},
+			mustRatio(1, 10),
 		},
 	}
 
@@ -181,12 +178,11 @@ func TestBuiltinDiv(t *testing.T) {
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
-			num, ok := result.(types.Number)
-			if !ok {
-				t.Fatalf("unexpected type: %T", num)
+			if _, ok := result.(types.Number); !ok {
+				t.Fatalf("unexpected type: %T", result)
 			}
-			if num.Value != tt.want.Value {
-				t.Errorf("got %v, want %v", num.Value, tt.want.Value)
+			if result.String() != tt.want.String() {
+				t.Errorf("got %v, want %v", result, tt.want)
 			}
 		})
 	}
@@ -204,8 +200,8 @@ func TestBuitinDiv_No_Args(t *testing.T) {
 
 func TestBuitlinDiv_Zero_Division(t *testing.T) {
 	result, err := builtinDiv([]types.Expr{
-		types.Number{Value: 5},
-		types.Number{Value: 0},
+		types.Fixnum{Value: 5},
+		types.Fixnum{Value: 0},
 	})
 	if err == nil {
 		t.Fatal("expected division by zero error")
@@ -214,3 +210,70 @@ func TestBuitlinDiv_Zero_Division(t *testing.T) {
 		t.Errorf("unexpected result: %v", result)
 	}
 }
+
+func mustRatio(num, den int64) types.Number {
+	r, err := types.NewRatio(num, den)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// 整数、bignum、分数、浮動小数点数が混ざったときの結果の型
+func TestArithmetic_Contagion(t *testing.T) {
+	env := NewGlobalEnvironment()
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"(/ 1 3)", "1/3"},
+		{"(/ 6 3)", "2"},
+		{"(/ 4 6)", "2/3"},
+		{"(+ 1/3 2/3)", "1"},
+		{"(* 2/3 3/2)", "1"},
+		{"(- 1/2)", "-1/2"},
+		{"(/ 2)", "1/2"},
+		{"(+ 1 2.5)", "3.5"},
+		{"(+ 1/2 0.5)", "1.0"},
+		{"(* 2 1.5)", "3.0"},
+		{"(/ 1.0 4)", "0.25"},
+		{"(+ 9223372036854775807 1)", "9223372036854775808"},
+		{"(- -9223372036854775808 1)", "-9223372036854775809"},
+		{"(* 4294967296 4294967296)", "18446744073709551616"},
+		{"(- (+ 9223372036854775807 1) 1)", "9223372036854775807"},
+		{"(- -9223372036854775808)", "9223372036854775808"},
+		{"(/ 18446744073709551616 4294967296)", "4294967296"},
+		{"(+ 9223372036854775808 0.0)", "9.223372036854776e18"},
+		{"(= 1 1.0)", "T"},
+		{"(= 1/2 0.5)", "T"},
+		{"(< 1 3/2 2 2.5)", "T"},
+		{"(< 1 1)", "NIL"},
+		{"(<= 1 1 2)", "T"},
+		{"(> 9223372036854775808 9223372036854775807)", "T"},
+		{"(>= 1/3 1/2)", "NIL"},
+		{"(= 9007199254740993 9007199254740992.0)", "NIL"},
+		{"(< 9007199254740992.0 9007199254740993)", "T"},
+		{"(= 1/3 0.3333333333333333)", "NIL"},
+		{"(< 100000000000000000000000000000000000000000000 (* 1e308 10))", "T"},
+		{"(> -100000000000000000000000000000000000000000000 (* -1e308 10))", "T"},
+		{"((lambda (n) (= n n)) (- (* 1e308 10) (* 1e308 10)))", "NIL"},
+		{"((lambda (n) (< n 1)) (- (* 1e308 10) (* 1e308 10)))", "NIL"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			expr, err := newTestParser(tt.input, env).Parse()
+			if err != nil {
+				t.Fatalf("parse error: %v", err)
+			}
+			result, err := Eval(expr, env)
+			if err != nil {
+				t.Fatalf("eval error: %v", err)
+			}
+			if result.String() != tt.want {
+				t.Errorf("got %s, want %s", result.String(), tt.want)
+			}
+		})
+	}
+}
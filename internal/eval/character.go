@@ -0,0 +1,199 @@
+// 文字を扱う組み込み関数
+package eval
+
+import (
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/koplec/gospl/internal/types"
+)
+
+func registerCharacterBuiltins(env *Environment) {
+	env.define("characterp", &BuiltinFunc{Name: "characterp", Fn: builtinCharacterp})
+	env.define("char", &BuiltinFunc{Name: "char", Fn: builtinChar})
+	env.define("char-code", &BuiltinFunc{Name: "char-code", Fn: builtinCharCode})
+	env.define("code-char", &BuiltinFunc{Name: "code-char", Fn: builtinCodeChar})
+	env.define("char-name", &BuiltinFunc{Name: "char-name", Fn: builtinCharName})
+	env.define("char-upcase", characterMapper("char-upcase", unicode.ToUpper))
+	env.define("char-downcase", characterMapper("char-downcase", unicode.ToLower))
+	env.define("alpha-char-p", characterPredicate("alpha-char-p", unicode.IsLetter))
+	env.define("alphanumericp", characterPredicate("alphanumericp", func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	}))
+	env.define("digit-char-p", &BuiltinFunc{Name: "digit-char-p", Fn: builtinDigitCharP})
+	env.define("upper-case-p", characterPredicate("upper-case-p", unicode.IsUpper))
+	env.define("lower-case-p", characterPredicate("lower-case-p", unicode.IsLower))
+	env.define("char=", characterComparison("char=", false, func(a, b rune) bool { return a == b }))
+	env.define("char<", characterComparison("char<", false, func(a, b rune) bool { return a < b }))
+	env.define("char>", characterComparison("char>", false, func(a, b rune) bool { return a > b }))
+	env.define("char<=", characterComparison("char<=", false, func(a, b rune) bool { return a <= b }))
+	env.define("char>=", characterComparison("char>=", false, func(a, b rune) bool { return a >= b }))
+	env.define("char-equal", characterComparison("char-equal", true, func(a, b rune) bool { return a == b }))
+	env.define("char-lessp", characterComparison("char-lessp", true, func(a, b rune) bool { return a < b }))
+	env.define("char-greaterp", characterComparison("char-greaterp", true, func(a, b rune) bool { return a > b }))
+}
+
+// (characterp x)
+func builtinCharacterp(args []types.Expr) (types.Expr, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("characterp requires exactly 1 argument")
+	}
+	_, ok := args[0].(types.Character)
+	return types.Boolean{Value: ok}, nil
+}
+
+// (char string index)
+// indexはバイトではなく文字で数える
+func builtinChar(args []types.Expr) (types.Expr, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("char requires exactly 2 arguments")
+	}
+	str, ok := args[0].(types.String)
+	if !ok {
+		return nil, fmt.Errorf("char: not a string: %v", args[0])
+	}
+	index, ok := args[1].(types.Fixnum)
+	if !ok {
+		return nil, fmt.Errorf("char: index must be an integer, got %v", args[1])
+	}
+	runes := []rune(str.Value)
+	if index.Value < 0 || index.Value >= int64(len(runes)) {
+		return nil, fmt.Errorf("char: index %d out of range for %v", index.Value, str)
+	}
+	return types.Character{Value: runes[index.Value]}, nil
+}
+
+// (char-code char)
+// Unicodeのコードポイント
+func builtinCharCode(args []types.Expr) (types.Expr, error) {
+	ch, err := characterArg("char-code", args)
+	if err != nil {
+		return nil, err
+	}
+	return types.Fixnum{Value: int64(ch)}, nil
+}
+
+// (code-char code)
+// コードポイントとして正しくないか、サロゲートのように文字にならなければNIL
+func builtinCodeChar(args []types.Expr) (types.Expr, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("code-char requires exactly 1 argument")
+	}
+	code, ok := args[0].(types.Fixnum)
+	if !ok {
+		return nil, fmt.Errorf("code-char: not an integer: %v", args[0])
+	}
+	if code.Value < 0 || code.Value > unicode.MaxRune || !utf8.ValidRune(rune(code.Value)) {
+		return &types.Nil{}, nil
+	}
+	return types.Character{Value: rune(code.Value)}, nil
+}
+
+// (char-name char)
+// 名前のない文字ならNIL
+func builtinCharName(args []types.Expr) (types.Expr, error) {
+	ch, err := characterArg("char-name", args)
+	if err != nil {
+		return nil, err
+	}
+	if name, ok := types.CharacterName(ch); ok {
+		return types.String{Value: name}, nil
+	}
+	return &types.Nil{}, nil
+}
+
+// (digit-char-p char &optional radix)
+// radix進数の数字ならその値、そうでなければNIL
+// radixを省略すると10
+func builtinDigitCharP(args []types.Expr) (types.Expr, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return nil, fmt.Errorf("digit-char-p requires 1 or 2 arguments")
+	}
+	ch, err := characterArg("digit-char-p", args[:1])
+	if err != nil {
+		return nil, err
+	}
+	radix := int64(10)
+	if len(args) == 2 {
+		r, ok := args[1].(types.Fixnum)
+		if !ok || r.Value < 2 || r.Value > 36 {
+			return nil, fmt.Errorf("digit-char-p: invalid radix: %v", args[1])
+		}
+		radix = r.Value
+	}
+
+	weight := int64(-1)
+	switch lower := unicode.ToLower(ch); {
+	case lower >= '0' && lower <= '9':
+		weight = int64(lower - '0')
+	case lower >= 'a' && lower <= 'z':
+		weight = int64(lower-'a') + 10
+	}
+	if weight < 0 || weight >= radix {
+		return &types.Nil{}, nil
+	}
+	return types.Fixnum{Value: weight}, nil
+}
+
+// (char-upcase char)のように、文字を別の文字に変える関数
+func characterMapper(name string, fn func(rune) rune) *BuiltinFunc {
+	return &BuiltinFunc{Name: name, Fn: func(args []types.Expr) (types.Expr, error) {
+		ch, err := characterArg(name, args)
+		if err != nil {
+			return nil, err
+		}
+		return types.Character{Value: fn(ch)}, nil
+	}}
+}
+
+// (alpha-char-p char)のように、文字の種類を調べる関数
+func characterPredicate(name string, fn func(rune) bool) *BuiltinFunc {
+	return &BuiltinFunc{Name: name, Fn: func(args []types.Expr) (types.Expr, error) {
+		ch, err := characterArg(name, args)
+		if err != nil {
+			return nil, err
+		}
+		return types.Boolean{Value: fn(ch)}, nil
+	}}
+}
+
+// (char= a b ...) (char< a b ...) など、文字の比較
+// 隣り合う引数がすべてcmpを満たせばT
+// ignoreCaseなら大文字小文字を区別しない（char-equalなど）
+func characterComparison(name string, ignoreCase bool, cmp func(a, b rune) bool) *BuiltinFunc {
+	return &BuiltinFunc{Name: name, Fn: func(args []types.Expr) (types.Expr, error) {
+		if len(args) == 0 {
+			return nil, fmt.Errorf("%s requires at least 1 argument", name)
+		}
+		chars := make([]rune, len(args))
+		for i, arg := range args {
+			ch, ok := arg.(types.Character)
+			if !ok {
+				return nil, fmt.Errorf("%s: not a character: %v", name, arg)
+			}
+			chars[i] = ch.Value
+			if ignoreCase {
+				chars[i] = unicode.ToLower(ch.Value)
+			}
+		}
+		for i := 1; i < len(chars); i++ {
+			if !cmp(chars[i-1], chars[i]) {
+				return types.Boolean{Value: false}, nil
+			}
+		}
+		return types.Boolean{Value: true}, nil
+	}}
+}
+
+// 引数が文字1つであることを確認して返す
+func characterArg(fn string, args []types.Expr) (rune, error) {
+	if len(args) != 1 {
+		return 0, fmt.Errorf("%s requires exactly 1 argument", fn)
+	}
+	ch, ok := args[0].(types.Character)
+	if !ok {
+		return 0, fmt.Errorf("%s: not a character: %v", fn, args[0])
+	}
+	return ch.Value, nil
+}
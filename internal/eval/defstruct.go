@@ -0,0 +1,391 @@
+// defstruct
+package eval
+
+import (
+	"fmt"
+
+	"github.com/koplec/gospl/internal/reader"
+	"github.com/koplec/gospl/internal/types"
+)
+
+// defstructのオプション
+type structOptions struct {
+	name        *types.Symbol
+	concName    string        // アクセサの名前の前に付ける文字列、省略するとNAME-
+	constructor *types.Symbol // nilなら作らない
+	predicate   *types.Symbol // nilなら作らない
+	copier      *types.Symbol // nilなら作らない
+	include     *types.StructType
+	overrides   []types.Expr // (:include parent slot-description*)で親のスロットを変える指定
+}
+
+// (defstruct name-and-options [documentation] slot-description*)
+// name-and-options は name か (name option*)
+// option は (:include parent slot-description*) (:conc-name prefix) (:constructor name) (:predicate name) (:copier name)
+// slot-description は slot か (slot default :type type :read-only flag)
+// :includeのslot-descriptionは、親のスロットの初期値の式を置き換える
+//
+// (defstruct point x (y 0)) は次の関数を定義する
+// (make-point :x 1 :y 2)  キーワード引数で作る、省略したスロットは初期値の式を評価した値
+// (point-x p) (point-y p) スロットの値、(setf (point-x p) 3)で書き換えられる
+// (point-p x)             pointかどうか
+// (copy-point p)          スロットの値をコピーした新しいpoint
+func evalDefstruct(args types.Expr, env *Environment) (types.Expr, error) {
+	items, err := listToSlice(args)
+	if err != nil || len(items) == 0 {
+		return nil, fmt.Errorf("defstruct requires a name")
+	}
+
+	opts, err := parseStructOptions(items[0], env)
+	if err != nil {
+		return nil, err
+	}
+
+	st := &types.StructType{Name: opts.name, Parent: opts.include, Constructor: opts.constructor}
+	if opts.include != nil {
+		for _, slot := range opts.include.Slots {
+			copied := *slot
+			st.Slots = append(st.Slots, &copied)
+		}
+	}
+	for _, desc := range opts.overrides {
+		if err := overrideStructSlot(st, desc); err != nil {
+			return nil, err
+		}
+	}
+
+	descriptions := items[1:]
+	// 先頭の文字列はドキュメント
+	if len(descriptions) > 0 {
+		if _, ok := descriptions[0].(types.String); ok {
+			descriptions = descriptions[1:]
+		}
+	}
+	for _, desc := range descriptions {
+		slot, err := parseStructSlot(desc)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := st.SlotIndex(slot.Name.Name); ok {
+			return nil, fmt.Errorf("defstruct %s: duplicate slot %s", opts.name, slot.Name)
+		}
+		st.Slots = append(st.Slots, slot)
+	}
+	env.Packages().DefineStructType(st)
+
+	if opts.constructor != nil {
+		opts.constructor.Function = structConstructor(opts.constructor.Name, st, env)
+	}
+	if opts.predicate != nil {
+		opts.predicate.Function = structPredicate(opts.predicate.Name, st)
+	}
+	if opts.copier != nil {
+		opts.copier.Function = structCopier(opts.copier.Name, st)
+	}
+	pkg := structPackage(opts.name, env)
+	for i, slot := range st.Slots {
+		name := opts.concName + slot.Name.Name
+		accessor := pkg.Intern(name)
+		accessor.Function = structAccessor(name, st, i)
+		env.setfFunctions()[accessor] = structSetter(name, st, i)
+	}
+
+	return opts.name, nil
+}
+
+// name か (name option*) を読む
+func parseStructOptions(expr types.Expr, env *Environment) (*structOptions, error) {
+	var options []types.Expr
+	name, ok := expr.(*types.Symbol)
+	if !ok {
+		items, err := listToSlice(expr)
+		if err != nil || len(items) == 0 {
+			return nil, fmt.Errorf("defstruct: invalid name and options: %v", expr)
+		}
+		if name, ok = items[0].(*types.Symbol); !ok {
+			return nil, fmt.Errorf("defstruct: structure name must be a symbol, got %v", items[0])
+		}
+		options = items[1:]
+	}
+
+	pkg := structPackage(name, env)
+	opts := &structOptions{
+		name:        name,
+		concName:    name.Name + "-",
+		constructor: pkg.Intern("make-" + name.Name),
+		predicate:   pkg.Intern(name.Name + "-p"),
+		copier:      pkg.Intern("copy-" + name.Name),
+	}
+
+	for _, option := range options {
+		// :constructor のように値のないオプションは、省略したときと同じ
+		if key, ok := option.(*types.Symbol); ok && key.IsKeyword() {
+			continue
+		}
+		items, err := listToSlice(option)
+		if err != nil || len(items) == 0 {
+			return nil, fmt.Errorf("defstruct %s: invalid option %v", name, option)
+		}
+		key, ok := items[0].(*types.Symbol)
+		if !ok || !key.IsKeyword() {
+			return nil, fmt.Errorf("defstruct %s: invalid option %v", name, option)
+		}
+		// 値を2つ以上とれるのは:includeだけ
+		if len(items) > 2 && key.Name != "include" {
+			return nil, fmt.Errorf("defstruct %s: invalid option %v", name, option)
+		}
+
+		var value types.Expr
+		if len(items) >= 2 {
+			value = items[1]
+		}
+		switch key.Name {
+		case "conc-name":
+			// (:conc-name) や (:conc-name nil) なら、スロットの名前そのままのアクセサになる
+			opts.concName = ""
+			if value != nil && isTrue(value) {
+				prefix, err := stringDesignator("defstruct", value)
+				if err != nil {
+					return nil, err
+				}
+				opts.concName = prefix
+			}
+		case "constructor":
+			if opts.constructor, err = structFunctionName(name, key, value, opts.constructor); err != nil {
+				return nil, err
+			}
+		case "predicate":
+			if opts.predicate, err = structFunctionName(name, key, value, opts.predicate); err != nil {
+				return nil, err
+			}
+		case "copier":
+			if opts.copier, err = structFunctionName(name, key, value, opts.copier); err != nil {
+				return nil, err
+			}
+		case "include":
+			parentName, ok := value.(*types.Symbol)
+			if !ok {
+				return nil, fmt.Errorf("defstruct %s: :include requires a structure name", name)
+			}
+			parent, ok := env.Packages().FindStructType(parentName)
+			if !ok {
+				return nil, fmt.Errorf("defstruct %s: unknown structure %s", name, parentName)
+			}
+			opts.include = parent
+			opts.overrides = items[2:]
+		default:
+			return nil, fmt.Errorf("defstruct %s: unknown option %s", name, key)
+		}
+	}
+	return opts, nil
+}
+
+// (:constructor name) などの関数の名前
+// 名前を省略したときはdefault、NILなら関数を作らないのでnil
+func structFunctionName(structName, key *types.Symbol, value, defaultName types.Expr) (*types.Symbol, error) {
+	if value == nil {
+		return defaultName.(*types.Symbol), nil
+	}
+	if !isTrue(value) {
+		return nil, nil
+	}
+	sym, ok := value.(*types.Symbol)
+	if !ok {
+		return nil, fmt.Errorf("defstruct %s: %s requires a symbol, got %v", structName, key, value)
+	}
+	return sym, nil
+}
+
+// slot か (slot default :type type :read-only flag) を読む
+// :typeは受け付けるだけで、値の型は調べない
+func parseStructSlot(expr types.Expr) (*types.StructSlot, error) {
+	if name, ok := expr.(*types.Symbol); ok {
+		return &types.StructSlot{Name: name, Default: &types.Nil{}}, nil
+	}
+	items, err := listToSlice(expr)
+	if err != nil || len(items) == 0 {
+		return nil, fmt.Errorf("defstruct: invalid slot description: %v", expr)
+	}
+	name, ok := items[0].(*types.Symbol)
+	if !ok {
+		return nil, fmt.Errorf("defstruct: slot name must be a symbol, got %v", items[0])
+	}
+
+	slot := &types.StructSlot{Name: name, Default: &types.Nil{}}
+	if len(items) > 1 {
+		slot.Default = items[1]
+	}
+	keys, err := keywordArgs("defstruct", items[min(len(items), 2):], "type", "read-only")
+	if err != nil {
+		return nil, err
+	}
+	if readOnly, ok := keys["read-only"]; ok {
+		slot.ReadOnly = isTrue(readOnly)
+	}
+	return slot, nil
+}
+
+// (:include parent slot-description*)のslot-descriptionで、親から受け継いだスロットを変える
+// 初期値の式を置き換え、:read-onlyを指定すれば書き換えられなくする
+// 親で:read-onlyのスロットは、書き換えられるようにはできない
+func overrideStructSlot(st *types.StructType, desc types.Expr) error {
+	override, err := parseStructSlot(desc)
+	if err != nil {
+		return err
+	}
+	i, ok := st.SlotIndex(override.Name.Name)
+	if !ok {
+		return fmt.Errorf("defstruct %s: included structure %s has no slot %s", st.Name, st.Parent.Name, override.Name)
+	}
+	slot := st.Slots[i]
+	slot.Default = override.Default
+	slot.ReadOnly = slot.ReadOnly || override.ReadOnly
+	return nil
+}
+
+// 構造体の関数の名前をインターンするパッケージ
+func structPackage(name *types.Symbol, env *Environment) *types.Package {
+	if name.Package == nil {
+		return env.Package()
+	}
+	return name.Package
+}
+
+// (make-NAME &key slot...)
+// 省略したスロットは、defstructを評価した環境で初期値の式を評価する
+func structConstructor(name string, st *types.StructType, env *Environment) *BuiltinFunc {
+	return &BuiltinFunc{Name: name, Fn: func(args []types.Expr) (types.Expr, error) {
+		allowed := make([]string, len(st.Slots))
+		for i, slot := range st.Slots {
+			allowed[i] = slot.Name.Name
+		}
+		keys, err := keywordArgs(name, args, allowed...)
+		if err != nil {
+			return nil, err
+		}
+
+		values := make([]types.Expr, len(st.Slots))
+		for i, slot := range st.Slots {
+			if value, ok := keys[slot.Name.Name]; ok {
+				values[i] = value
+				continue
+			}
+			if values[i], err = evalPrimary(slot.Default, env); err != nil {
+				return nil, err
+			}
+		}
+		return &types.Structure{Type: st, Values: values}, nil
+	}}
+}
+
+// #S(name slot value ...)
+// スロットの名前をキーワードにして、nameのコンストラクタを呼んで作る
+// 値は評価しないで、読んだまま渡す
+// 構造体は#S(point :x 1 :y 2)の形で表示するので、読み直すと同じスロットの値の構造体になる
+func readStructure(p *reader.Parser, env *Environment) (types.Expr, error) {
+	expr, err := p.Parse()
+	if err != nil {
+		return nil, err
+	}
+	items, err := listToSlice(expr)
+	if err != nil || len(items)%2 != 1 {
+		return nil, fmt.Errorf("#S: invalid structure syntax: %v", expr)
+	}
+	name, ok := items[0].(*types.Symbol)
+	if !ok {
+		return nil, fmt.Errorf("#S: structure name must be a symbol, got %v", items[0])
+	}
+	st, ok := env.Packages().FindStructType(name)
+	if !ok {
+		return nil, fmt.Errorf("#S: unknown structure %s", name)
+	}
+	if st.Constructor == nil {
+		return nil, fmt.Errorf("#S: structure %s has no constructor", name)
+	}
+
+	args := make([]types.Expr, 0, len(items)-1)
+	for i := 1; i < len(items); i += 2 {
+		slot, ok := items[i].(*types.Symbol)
+		if !ok {
+			return nil, fmt.Errorf("#S: slot name must be a symbol, got %v", items[i])
+		}
+		args = append(args, env.Packages().Keyword(slot.Name), items[i+1])
+	}
+	fn, err := globalFunction(st.Constructor)
+	if err != nil {
+		return nil, err
+	}
+	result, err := apply(fn, args)
+	if err != nil {
+		return nil, err
+	}
+	return types.PrimaryValue(result), nil
+}
+
+// (NAME-p object)
+// :includeした型のインスタンスもT
+func structPredicate(name string, st *types.StructType) *BuiltinFunc {
+	return &BuiltinFunc{Name: name, Fn: func(args []types.Expr) (types.Expr, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("%s requires exactly 1 argument", name)
+		}
+		s, ok := args[0].(*types.Structure)
+		return types.Boolean{Value: ok && s.Type.IsSubtypeOf(st)}, nil
+	}}
+}
+
+// (copy-NAME object)
+// スロットの値そのものはコピーしない
+func structCopier(name string, st *types.StructType) *BuiltinFunc {
+	return &BuiltinFunc{Name: name, Fn: func(args []types.Expr) (types.Expr, error) {
+		s, err := structArg(name, st, args)
+		if err != nil {
+			return nil, err
+		}
+		return &types.Structure{Type: s.Type, Values: append([]types.Expr(nil), s.Values...)}, nil
+	}}
+}
+
+// (NAME-SLOT object)
+// objectがstの型（か:includeした型）でなければエラー
+func structAccessor(name string, st *types.StructType, index int) *BuiltinFunc {
+	return &BuiltinFunc{Name: name, Fn: func(args []types.Expr) (types.Expr, error) {
+		s, err := structArg(name, st, args)
+		if err != nil {
+			return nil, err
+		}
+		return s.Values[index], nil
+	}}
+}
+
+// (setf (NAME-SLOT object) value)
+// :read-onlyのスロットはエラー
+func structSetter(name string, st *types.StructType, index int) *BuiltinFunc {
+	setfName := "(setf " + name + ")"
+	return &BuiltinFunc{Name: setfName, Fn: func(args []types.Expr) (types.Expr, error) {
+		if len(args) == 0 {
+			return nil, fmt.Errorf("%s requires exactly 2 arguments", setfName)
+		}
+		s, err := structArg(setfName, st, args[1:])
+		if err != nil {
+			return nil, err
+		}
+		if slot := st.Slots[index]; slot.ReadOnly {
+			return nil, fmt.Errorf("%s: slot %s of %s is read-only", setfName, slot.Name, st.Name)
+		}
+		s.Values[index] = args[0]
+		return args[0], nil
+	}}
+}
+
+// 引数がstの型の構造体1つであることを確認して返す
+func structArg(fn string, st *types.StructType, args []types.Expr) (*types.Structure, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("%s requires exactly 1 argument", fn)
+	}
+	s, ok := args[0].(*types.Structure)
+	if !ok || !s.Type.IsSubtypeOf(st) {
+		return nil, fmt.Errorf("%s: not a %s: %v", fn, st.Name, args[0])
+	}
+	return s, nil
+}
@@ -0,0 +1,89 @@
+// グローバル変数と定数の定義
+package eval
+
+import (
+	"fmt"
+
+	"github.com/koplec/gospl/internal/types"
+)
+
+// (defvar name [initial-value [documentation]])
+// nameをスペシャル変数にする
+// すでに値があれば、initial-valueは評価せず値も変えない
+func evalDefvar(args types.Expr, env *Environment) (types.Expr, error) {
+	sym, items, err := parseVariableDefinition(SpecialFormDefvar, args, 0)
+	if err != nil {
+		return nil, err
+	}
+	sym.Special = true
+	if len(items) > 0 && sym.Value == nil {
+		value, err := evalPrimary(items[0], env)
+		if err != nil {
+			return nil, err
+		}
+		sym.Value = value
+	}
+	return sym, nil
+}
+
+// (defparameter name initial-value [documentation])
+// defvarと違い、いつも値を設定し直す
+func evalDefparameter(args types.Expr, env *Environment) (types.Expr, error) {
+	sym, items, err := parseVariableDefinition(SpecialFormDefparameter, args, 1)
+	if err != nil {
+		return nil, err
+	}
+	value, err := evalPrimary(items[0], env)
+	if err != nil {
+		return nil, err
+	}
+	sym.Special = true
+	sym.Value = value
+	return sym, nil
+}
+
+// (defconstant name value [documentation])
+// 定数はsetqで書き換えたり、letで束縛したりできない
+// eqlな値でなら定義し直してもよい
+func evalDefconstant(args types.Expr, env *Environment) (types.Expr, error) {
+	sym, items, err := parseVariableDefinition(SpecialFormDefconstant, args, 1)
+	if err != nil {
+		return nil, err
+	}
+	value, err := evalPrimary(items[0], env)
+	if err != nil {
+		return nil, err
+	}
+	if sym.Constant && !types.Eql(sym.Value, value) {
+		return nil, fmt.Errorf("defconstant: %s is already defined as %v", sym, sym.Value)
+	}
+	sym.Constant = true
+	sym.Value = value
+	return sym, nil
+}
+
+// (name value [documentation])の名前と、値とドキュメントの式を取り出す
+// 値の式はrequired個以上なければエラー
+// 定数はdefconstantでしか定義し直せない
+func parseVariableDefinition(fn string, args types.Expr, required int) (*types.Symbol, []types.Expr, error) {
+	items, err := listToSlice(args)
+	if err != nil || len(items) < required+1 || len(items) > 3 {
+		if required == 0 {
+			return nil, nil, fmt.Errorf("%s requires 1 to 3 arguments", fn)
+		}
+		return nil, nil, fmt.Errorf("%s requires 2 or 3 arguments", fn)
+	}
+	sym, ok := items[0].(*types.Symbol)
+	if !ok || sym.IsKeyword() {
+		return nil, nil, fmt.Errorf("%s: variable name must be a non-keyword symbol, got %v", fn, items[0])
+	}
+	if sym.Constant && fn != SpecialFormDefconstant {
+		return nil, nil, fmt.Errorf("%s: %s is a constant", fn, sym)
+	}
+	if len(items) == 3 {
+		if _, ok := items[2].(types.String); !ok {
+			return nil, nil, fmt.Errorf("%s: documentation must be a string, got %v", fn, items[2])
+		}
+	}
+	return sym, items[1:], nil
+}
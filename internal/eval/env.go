@@ -2,56 +2,254 @@ package eval
 
 import (
 	"fmt"
+	"slices"
 
+	"github.com/koplec/gospl/internal/reader"
 	"github.com/koplec/gospl/internal/types"
 )
 
 // 変数の束縛の管理
+// 局所変数はシンボルをキーにした表に持ち、グローバルな値はシンボルの値セルに持つ
+// 関数は変数とは別の名前空間（Lisp-2）で、局所関数は別の表に、グローバルな関数はシンボルの関数セルに持つ
 type Environment struct {
-	bindings map[string]types.Expr
-	parent   *Environment //親環境、スコープチェーンに利用
+	bindings  map[*types.Symbol]types.Expr //局所変数の束縛、グローバル環境では使わない
+	functions map[*types.Symbol]types.Expr //fletやlabelsの局所関数の束縛、グローバル環境では使わない
+	parent    *Environment                 //親環境、スコープチェーンに利用
+	sources   []*reader.SourceMap          //読み込んだソースの位置情報、グローバル環境だけが持つ
+	packages  *types.Registry              //パッケージの表、グローバル環境だけが持つ
+	setf      map[*types.Symbol]types.Expr //setfの関数の表、グローバル環境だけが持つ
 }
 
 func NewEnvironment(parent *Environment) *Environment {
 	return &Environment{
-		bindings: make(map[string]types.Expr),
-		parent:   parent,
+		bindings:  make(map[*types.Symbol]types.Expr),
+		functions: make(map[*types.Symbol]types.Expr),
+		parent:    parent,
 	}
 }
 
+// グローバル環境を作る
+// 環境ごとにパッケージの表を作るので、シンボルも関数や構造体の定義も、ほかのグローバル環境とは共有しない
 func NewGlobalEnvironment() *Environment {
 	env := NewEnvironment(nil)
+	env.packages = types.NewRegistry()
+	env.setf = make(map[*types.Symbol]types.Expr)
+	registerSpecialForms(env)
 
-	env.Set("+", BuiltinFunc{Name: "+", Fn: builtinAdd})
-	env.Set("-", BuiltinFunc{Name: "-", Fn: builtinSub})
-	env.Set("*", BuiltinFunc{Name: "*", Fn: builtinMul})
-	env.Set("/", BuiltinFunc{Name: "/", Fn: builtinDiv})
-	env.Set("apply", BuiltinFunc{
+	env.define("+", &BuiltinFunc{Name: "+", Fn: builtinAdd})
+	env.define("-", &BuiltinFunc{Name: "-", Fn: builtinSub})
+	env.define("*", &BuiltinFunc{Name: "*", Fn: builtinMul})
+	env.define("/", &BuiltinFunc{Name: "/", Fn: builtinDiv})
+	env.define("=", &BuiltinFunc{Name: "=", Fn: numberComparison("=", func(c int) bool { return c == 0 })})
+	env.define("<", &BuiltinFunc{Name: "<", Fn: numberComparison("<", func(c int) bool { return c < 0 })})
+	env.define(">", &BuiltinFunc{Name: ">", Fn: numberComparison(">", func(c int) bool { return c > 0 })})
+	env.define("<=", &BuiltinFunc{Name: "<=", Fn: numberComparison("<=", func(c int) bool { return c <= 0 })})
+	env.define(">=", &BuiltinFunc{Name: ">=", Fn: numberComparison(">=", func(c int) bool { return c >= 0 })})
+	env.define("apply", &BuiltinFunc{
 		Name: "apply", Fn: builtinApply,
 	})
-	env.Set("funcall", BuiltinFunc{
+	env.define("funcall", &BuiltinFunc{
 		Name: "funcall",
 		Fn:   builtinFuncall,
 	})
 
+	registerReadtableBuiltins(env)
+	registerStreamBuiltins(env)
+	registerPackageBuiltins(env)
+	registerSymbolBuiltins(env)
+	registerCharacterBuiltins(env)
+	registerArrayBuiltins(env)
+	registerHashTableBuiltins(env)
+	registerValuesBuiltins(env)
+	registerListBuiltins(env)
+	registerSetfBuiltins(env)
+
 	return env
 }
 
-func (e *Environment) Set(name string, value types.Expr) {
-	e.bindings[name] = value
+// 組み込み関数の名前を、common-lispパッケージの外部シンボルとして登録する
+// 関数はシンボルの関数セルに入れる
+// common-lisp-userはcommon-lispをuseしているので、carもcl:carも同じシンボルになる
+func (e *Environment) define(name string, fn types.Expr) {
+	e.SetFunction(e.Packages().CommonLisp.Export(name), fn)
+}
+
+// この環境のパッケージの表
+func (e *Environment) Packages() *types.Registry {
+	return e.root().packages
+}
+
+// (setf (accessor args...) value)で呼ぶ関数の表
+// キーはアクセサのシンボルで、関数は(value args...)を引数にとり、valueを返す
+// 組み込みのアクセサのほかに、defstructのアクセサ、defsetf、(defun (setf foo) ...)で増える
+func (e *Environment) setfFunctions() map[*types.Symbol]types.Expr {
+	return e.root().setf
+}
+
+// 修飾子のないシンボルを探すパッケージ
+// REPLやファイルの読み込みで、次の式を読むParserに渡す
+func (e *Environment) Package() *types.Package {
+	return e.Packages().CommonLispUser
 }
 
-func (e *Environment) Get(name string) (types.Expr, error) {
+// common-lispパッケージのシンボルかどうか
+func isCommonLisp(sym *types.Symbol) bool {
+	return sym.Package != nil && sym.Package == sym.Package.Registry().CommonLisp
+}
+
+// この環境でsymにvalueを束縛する
+// グローバル環境ならシンボルの値セルに入れる
+func (e *Environment) Set(sym *types.Symbol, value types.Expr) {
+	if e.parent == nil {
+		sym.Value = value
+		return
+	}
+	e.bindings[sym] = value
+}
+
+// symが束縛されている一番近い環境で、値をvalueに書き換える
+// setqで使う
+// どの環境にも束縛がなければ、グローバルな値にする
+// スペシャル変数は今の動的な束縛、つまり値セルを書き換える
+func (e *Environment) Assign(sym *types.Symbol, value types.Expr) error {
+	if sym.Constant {
+		return fmt.Errorf("cannot assign to constant %s", sym)
+	}
+	if !sym.Special {
+		for env := e; env.parent != nil; env = env.parent {
+			if _, ok := env.bindings[sym]; ok {
+				env.bindings[sym] = value
+				return nil
+			}
+		}
+	}
+	sym.Value = value
+	return nil
+}
+
+// 変数を束縛する
+// let、lambdaの引数、multiple-value-bindで使う
+// レキシカルな変数はこの環境に束縛する
+// スペシャル変数は値セルを書き換えて、元の値をdynに覚えておく
+// 束縛を作った式を抜けるときに、dyn.restoreで元に戻す
+func (e *Environment) bind(sym *types.Symbol, value types.Expr, dyn *dynamicBindings) error {
+	if sym.Constant {
+		return fmt.Errorf("cannot bind constant %s", sym)
+	}
+	if sym.Special {
+		*dyn = append(*dyn, dynamicBinding{sym: sym, old: sym.Value})
+		sym.Value = value
+		return nil
+	}
+	e.Set(sym, value)
+	return nil
+}
+
+// スペシャル変数の動的な束縛と、束縛する前の値
+type dynamicBinding struct {
+	sym *types.Symbol
+	old types.Expr // nilなら未束縛だった
+}
+
+type dynamicBindings []dynamicBinding
+
+// 束縛する前の値に戻す
+// エラーで抜けるときも戻るように、deferで呼ぶ
+func (d *dynamicBindings) restore() {
+	for i := len(*d) - 1; i >= 0; i-- {
+		(*d)[i].sym.Value = (*d)[i].old
+	}
+}
+
+func (e *Environment) Get(sym *types.Symbol) (types.Expr, error) {
+	//グローバル環境と、スペシャル変数はシンボルの値セルを見る
+	if e.parent == nil || sym.Special {
+		if sym.Value != nil {
+			return sym.Value, nil
+		}
+		//なかった。。。
+		return nil, fmt.Errorf("undefined variable: %s", sym)
+	}
+
 	//現在の環境で探す
-	if val, ok := e.bindings[name]; ok {
+	if val, ok := e.bindings[sym]; ok {
 		return val, nil
 	}
 
 	//親環境で探す
-	if e.parent != nil {
-		return e.parent.Get(name)
+	return e.parent.Get(sym)
+}
+
+// この環境でsymに関数fnを束縛する
+// グローバル環境ならシンボルの関数セルに入れる
+func (e *Environment) SetFunction(sym *types.Symbol, fn types.Expr) {
+	if e.parent == nil {
+		sym.Function = fn
+		return
+	}
+	e.functions[sym] = fn
+}
+
+// symの関数を探す
+// 変数とは名前空間が別なので、(let ((list 1)) (list list))の先頭のlistは関数のlistになる
+func (e *Environment) GetFunction(sym *types.Symbol) (types.Expr, error) {
+	for env := e; env.parent != nil; env = env.parent {
+		if fn, ok := env.functions[sym]; ok {
+			return fn, nil
+		}
+	}
+	return globalFunction(sym)
+}
+
+// シンボルの関数セルの関数
+func globalFunction(sym *types.Symbol) (types.Expr, error) {
+	if sym.Function == nil {
+		return nil, fmt.Errorf("undefined function: %s", sym)
+	}
+	return sym.Function, nil
+}
+
+// 読み込んだソースの位置情報を登録する
+// 評価中のエラーに、どのファイルの何行目の式で起きたかを付けるために使う
+// 登録した対応表は、RemoveSourceMapで取り除くまで持ち続ける
+// 式を評価し終えたら取り除くと、その式の中で定義した関数を後で呼んだときのエラーには位置が付かない
+func (e *Environment) AddSourceMap(m *reader.SourceMap) {
+	root := e.root()
+	root.sources = append(root.sources, m)
+}
+
+// AddSourceMapで登録した位置情報を取り除く
+func (e *Environment) RemoveSourceMap(m *reader.SourceMap) {
+	root := e.root()
+	root.sources = slices.DeleteFunc(root.sources, func(s *reader.SourceMap) bool {
+		return s == m
+	})
+}
+
+// リストの式の範囲を探す
+func (e *Environment) spanOf(expr types.Expr) (reader.Span, bool) {
+	for _, m := range e.root().sources {
+		if span, ok := m.Span(expr); ok {
+			return span, true
+		}
+	}
+	return reader.Span{}, false
+}
+
+// cellのCarにある式の範囲を探す
+func (e *Environment) elemSpanOf(cell *types.Cons) (reader.Span, bool) {
+	for _, m := range e.root().sources {
+		if span, ok := m.ElemSpan(cell); ok {
+			return span, true
+		}
 	}
+	return reader.Span{}, false
+}
 
-	//なかった。。。
-	return nil, fmt.Errorf("undefined variable: %s", name)
+// グローバル環境
+func (e *Environment) root() *Environment {
+	for e.parent != nil {
+		e = e.parent
+	}
+	return e
 }
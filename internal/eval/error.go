@@ -0,0 +1,36 @@
+package eval
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/koplec/gospl/internal/reader"
+)
+
+// 評価中のエラーに、エラーが起きた式のソースコード上の位置を付けたもの
+// 位置は、エラーが起きたいちばん内側の式のもの
+type RuntimeError struct {
+	Span reader.Span
+	Err  error
+}
+
+func (e *RuntimeError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Span, e.Err)
+}
+
+func (e *RuntimeError) Unwrap() error {
+	return e.Err
+}
+
+// errに位置を付ける
+// すでに内側の式の位置が付いているときはそのまま返す
+func withSpan(err error, span reader.Span, ok bool) error {
+	if !ok {
+		return err
+	}
+	var rerr *RuntimeError
+	if errors.As(err, &rerr) {
+		return err
+	}
+	return &RuntimeError{Span: span, Err: err}
+}
@@ -14,15 +14,28 @@ func Eval(expr types.Expr, env *Environment) (types.Expr, error) {
 	case types.String:
 		//文字列もそのまま
 		return e, nil
+	case types.Character:
+		//文字もそのまま
+		return e, nil
+	case *types.Array:
+		//#(1 2 3)のようなベクタや配列もそのまま
+		return e, nil
+	case *types.Structure:
+		//構造体もそのまま
+		return e, nil
 	case types.Boolean:
 		//真偽値もそのまま
 		return e, nil
 	case *types.Nil:
 		return e, nil
 
-	case types.Symbol:
+	case *types.Symbol:
+		//キーワードは評価するとそれ自身
+		if e.IsKeyword() {
+			return e, nil
+		}
 		//シンボルは環境から値を取得
-		return env.Get(e.Name)
+		return env.Get(e)
 	case *types.Cons:
 		//リストは関数適用
 		return evalList(e, env)
@@ -31,8 +44,29 @@ func Eval(expr types.Expr, env *Environment) (types.Expr, error) {
 	}
 }
 
+// 式を評価して、最初の値だけを返す
+// 関数の引数やifの条件のように、多値を受け取らない位置で使う
+// 関数の本体の最後の式やifの分岐のような末尾の位置では、Evalの多値をそのまま返す
+func evalPrimary(expr types.Expr, env *Environment) (types.Expr, error) {
+	result, err := Eval(expr, env)
+	if err != nil {
+		return nil, err
+	}
+	return types.PrimaryValue(result), nil
+}
+
 // リスト（関数適用）を評価
+// エラーが起きたときは、位置がわかればこのリストの位置を付ける
 func evalList(list *types.Cons, env *Environment) (types.Expr, error) {
+	result, err := evalForm(list, env)
+	if err != nil {
+		span, ok := env.spanOf(list)
+		return nil, withSpan(err, span, ok)
+	}
+	return result, nil
+}
+
+func evalForm(list *types.Cons, env *Environment) (types.Expr, error) {
 	// Goのnilポインタチェック（通常は発生しないはず、防衛的に記述）
 	// 空リストは*types.Nil{}として表現されるので、Eval内のcase *types.Nilで対応しているため、個々には到達しないはず
 	if list == nil {
@@ -44,19 +78,18 @@ func evalList(list *types.Cons, env *Environment) (types.Expr, error) {
 	first := list.Car
 
 	//シンボルなら、special formかどうかを確認
-	if sym, ok := first.(types.Symbol); ok {
-		if isSpecialForm(sym.Name) {
-			//list.Cdrについて
-			//もとのlistが(hoge bar baz)だったら(bar baz)が渡される
-			//quoteの時は難しくて、(quote (a b c))だったら((a b c))が渡される。
-			//(quote x)だったら(cons 'quote (cons 'x nil))という構造なので、
-			// list.Cdrは(x)=(cons 'x nil)
-			return evalSpecialForm(sym.Name, list.Cdr, env)
-		}
+	if name, ok := specialFormName(first); ok {
+		//list.Cdrについて
+		//もとのlistが(hoge bar baz)だったら(bar baz)が渡される
+		//quoteの時は難しくて、(quote (a b c))だったら((a b c))が渡される。
+		//(quote x)だったら(cons 'quote (cons 'x nil))という構造なので、
+		// list.Cdrは(x)=(cons 'x nil)
+		return evalSpecialForm(name, list.Cdr, env)
 	}
 
-	//先頭要素(関数)を評価
-	fn, err := Eval(first, env)
+	//先頭要素から関数を取り出す
+	//先頭は評価せず、シンボルなら関数の名前空間から探す
+	fn, err := evalFunctionName(first, env)
 	if err != nil {
 		return nil, err
 	}
@@ -71,6 +104,21 @@ func evalList(list *types.Cons, env *Environment) (types.Expr, error) {
 	return apply(fn, args)
 }
 
+// 関数の名前を関数にする
+// シンボルなら関数の名前空間から探し、(lambda ...)ならクロージャを作る
+// (function name)や、関数呼び出しの先頭で使う
+func evalFunctionName(expr types.Expr, env *Environment) (types.Expr, error) {
+	switch e := expr.(type) {
+	case *types.Symbol:
+		return env.GetFunction(e)
+	case *types.Cons:
+		if name, ok := specialFormName(e.Car); ok && name == SpecialFormLambda {
+			return evalLambda(e.Cdr, env)
+		}
+	}
+	return nil, fmt.Errorf("not a function name: %v", expr)
+}
+
 // 引数リストを評価
 // 引数のsliceにする
 func evalArgs(expr types.Expr, env *Environment) ([]types.Expr, error) {
@@ -91,9 +139,12 @@ func evalArgs(expr types.Expr, env *Environment) ([]types.Expr, error) {
 		}
 
 		//引数を評価
-		arg, err := Eval(cons.Car, env)
+		//(f x)のxが未定義のときなどは、x自体の位置を付ける
+		//引数は多値の最初の値だけを使う
+		arg, err := evalPrimary(cons.Car, env)
 		if err != nil {
-			return nil, err
+			span, ok := env.elemSpanOf(cons)
+			return nil, withSpan(err, span, ok)
 		}
 		args = append(args, arg)
 		current = cons.Cdr
@@ -105,15 +156,23 @@ func evalArgs(expr types.Expr, env *Environment) ([]types.Expr, error) {
 // 関数を引数に適用
 func apply(fn types.Expr, args []types.Expr) (types.Expr, error) {
 	switch f := fn.(type) {
-	case BuiltinFunc:
+	case *BuiltinFunc:
 		return f.Call(args)
 
 	case *Lambda:
 		//ユーザ定義関数
 		return applyLambda(f, args)
+
+	case *types.Symbol:
+		//(funcall 'car x)のようなシンボルは、グローバルな関数を呼ぶ
+		global, err := globalFunction(f)
+		if err != nil {
+			return nil, err
+		}
+		return apply(global, args)
 	}
 	//まずは組み込む関数のみのサポート
-	builtin, ok := fn.(BuiltinFunc)
+	builtin, ok := fn.(*BuiltinFunc)
 	if !ok {
 		return nil, fmt.Errorf("not a function: %v", fn)
 	}
@@ -136,10 +195,27 @@ func applyLambda(lambda *Lambda, args []types.Expr) (types.Expr, error) {
 	newEnv := NewEnvironment(lambda.Env)
 
 	//仮引数に実引数を束縛
+	//スペシャル変数の引数は動的に束縛し、関数を抜けるときに元に戻す
+	var dyn dynamicBindings
+	defer dyn.restore()
 	for i, param := range lambda.Params {
-		newEnv.Set(param, args[i])
+		if err := newEnv.bind(param, args[i], &dyn); err != nil {
+			return nil, err
+		}
 	}
 
 	// 関数本体を新しい環境で評価
-	return Eval(lambda.Body, newEnv)
+	return evalBody(lambda.Body, newEnv)
+}
+
+// Lispの真偽判定
+// NILとBoolean{Value:false}以外はすべて真
+func isTrue(expr types.Expr) bool {
+	if _, ok := expr.(*types.Nil); ok {
+		return false
+	}
+	if b, ok := expr.(types.Boolean); ok && !b.Value {
+		return false
+	}
+	return true
 }
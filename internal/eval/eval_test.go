@@ -1,9 +1,13 @@
 package eval
 
 import (
+	"errors"
+	"io"
+	"strings"
 	"testing"
 
 	"github.com/koplec/gospl/internal/reader"
+	"github.com/koplec/gospl/internal/types"
 )
 
 func TestEval(t *testing.T) {
@@ -35,7 +39,7 @@ func TestEval(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			parser := reader.NewParser(tt.input)
+			parser := newTestParser(tt.input, env)
 			expr, err := parser.Parse()
 			if err != nil {
 				t.Fatalf("parse error: %v", err)
@@ -72,7 +76,7 @@ func TestEval_Errors(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			parser := reader.NewParser(tt.input)
+			parser := newTestParser(tt.input, env)
 			expr, err := parser.Parse()
 			// parseはできる
 			if err != nil {
@@ -86,3 +90,1132 @@ func TestEval_Errors(t *testing.T) {
 		})
 	}
 }
+
+func TestEvalQuasiquote(t *testing.T) {
+	env := NewGlobalEnvironment()
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"atom", "`x", "x"},
+		{"no unquote", "`(a b c)", "(a b c)"},
+		{"unquote", "((lambda (x) `(a ,x c)) 1)", "(a 1 c)"},
+		{"unquote expression", "`(1 ,(+ 1 1) 3)", "(1 2 3)"},
+		{"unquote nested list", "`(a (b ,(* 2 3)))", "(a (b 6))"},
+		{"splicing", "((lambda (xs) `(a ,@xs d)) '(b c))", "(a b c d)"},
+		{"splicing at end", "((lambda (xs) `(a ,@xs)) '(b c))", "(a b c)"},
+		{"splicing nil", "((lambda (xs) `(a ,@xs b)) '())", "(a b)"},
+		{"splicing only nil", "((lambda (xs) `(,@xs)) '())", "NIL"},
+		{"dotted unquote", "((lambda (x) `(a . ,x)) 1)", "(a . 1)"},
+		{"dotted unquote list", "((lambda (x) `(a . ,x)) '(b c))", "(a b c)"},
+		{"nested quasiquote", "``(a ,(b ,(+ 1 2)))", "(quasiquote (a (unquote (b 3))))"},
+		{"nested double unquote", "((lambda (x) ``(a ,,x)) 'y)", "(quasiquote (a (unquote y)))"},
+		{"nested unquote splicing", "((lambda (xs) ``(a ,,@xs)) '(b c))", "(quasiquote (a (unquote b c)))"},
+		{"quote inside", "((lambda (x) `(a ',x)) 1)", "(a (quote 1))"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := newTestParser(tt.input, env)
+			expr, err := parser.Parse()
+			if err != nil {
+				t.Fatalf("parse error: %v", err)
+			}
+
+			result, err := Eval(expr, env)
+			if err != nil {
+				t.Fatalf("eval error: %v", err)
+			}
+			if result.String() != tt.want {
+				t.Errorf("got %s, want %s", result.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestEvalQuasiquote_Errors(t *testing.T) {
+	env := NewGlobalEnvironment()
+
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"unquote outside backquote", ",x"},
+		{"splicing outside backquote", ",@x"},
+		{"splicing not in list", "`,@'(1 2)"},
+		{"splicing non list", "`(a ,@1)"},
+		{"undefined variable", "`(a ,undefined-x)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := newTestParser(tt.input, env)
+			expr, err := parser.Parse()
+			if err != nil {
+				t.Fatalf("parse error: %v", err)
+			}
+
+			_, err = Eval(expr, env)
+			if err == nil {
+				t.Fatalf("expected error, got nil")
+			}
+		})
+	}
+}
+
+// 評価中のエラーにソースコードの位置が付く
+func TestEval_ErrorSpan(t *testing.T) {
+	input := `(defun add-y (x)
+  (+ x y))
+
+(add-y 1)`
+
+	env := NewGlobalEnvironment()
+	r := reader.NewFileReader(strings.NewReader(input), "script.lisp")
+	r.SetPackage(env.Package())
+
+	// ファイル全体の位置情報を持っておくので、前に定義した関数の中のエラーにも位置が付く
+	var err error
+	for err == nil {
+		var form reader.Form
+		form, err = r.Read()
+		if err != nil {
+			t.Fatalf("read error: %v", err)
+		}
+		env.AddSourceMap(form.Sources)
+		_, err = Eval(form.Expr, env)
+	}
+
+	var rerr *RuntimeError
+	if !errors.As(err, &rerr) {
+		t.Fatalf("expected RuntimeError, got %T: %v", err, err)
+	}
+	// 未定義のyを指す
+	if rerr.Span.File != "script.lisp" || rerr.Span.Start.Line != 2 || rerr.Span.Start.Column != 8 {
+		t.Errorf("span = %+v", rerr.Span)
+	}
+	if err.Error() != "script.lisp:2:8: undefined variable: y" {
+		t.Errorf("message = %s", err.Error())
+	}
+}
+
+// Lispからリーダマクロを定義して、次の式から使う
+func TestEval_ReaderMacros(t *testing.T) {
+	input := `(set-dispatch-macro-character "#" "date" (lambda (stream sub-char arg) ` + "`" + `(date ,(read stream))))
+(defun date (s) s)
+#date"2026-01-01"
+(set-dispatch-macro-character "#" "day" (lambda (stream sub-char arg) (symbol-name (read stream))))
+#day 2026-01-01
+(set-macro-character "!" (lambda (stream char) ` + "`" + `(quote (not ,(read stream)))))
+!foo
+(set-dispatch-macro-character "#" "q" (lambda (stream sub-char arg) (list sub-char arg (read-char stream) (peek-char nil stream) (read-char stream))))
+'#qab
+(set-dispatch-macro-character "#" "w" (lambda (stream sub-char arg) (list (peek-char t stream) (read stream))))
+'#w   x
+(copy-readtable)
+(set-dispatch-macro-character "#" "e" (lambda (stream sub-char arg) (list (read stream nil :eof) (read-char stream nil :eof))))
+'#e`
+
+	env := NewGlobalEnvironment()
+	r := reader.NewReader(strings.NewReader(input))
+	r.SetPackage(env.Package())
+
+	var results []string
+	for {
+		r.SetReadtable(env.Readtable())
+		form, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("read error: %v", err)
+		}
+		result, err := Eval(form.Expr, env)
+		if err != nil {
+			t.Fatalf("eval error: %v", err)
+		}
+		results = append(results, result.String())
+	}
+
+	expected := []string{"T", "date", `"2026-01-01"`, "T", `"2026-01-01"`, "T", "(not foo)",
+		"T", `(#\q NIL #\a #\b #\b)`, "T", `(#\x x)`, "#<READTABLE>", "T", "(:eof :eof)"}
+	if strings.Join(results, " ") != strings.Join(expected, " ") {
+		t.Errorf("got %v, want %v", results, expected)
+	}
+}
+
+// リーダマクロの関数に渡したストリームのエラー
+func TestEval_ReaderMacroStream(t *testing.T) {
+	env := NewGlobalEnvironment()
+	evalForms(t, env, `(defvar *saved* nil)
+(set-dispatch-macro-character "#" "date" (lambda (stream sub-char arg) (read stream)))
+(set-dispatch-macro-character "#" "keep" (lambda (stream sub-char arg) (setq *saved* stream) 1))
+#keep`)
+
+	// 入力が終わったら、REPLが続きの行を読めるエラーにする
+	for _, input := range []string{"#date", "#date   "} {
+		parser := newTestParser(input, env)
+		parser.SetReadtable(env.Readtable())
+		_, err := parser.Parse()
+		if !reader.IsIncomplete(err) {
+			t.Errorf("%q: expected incomplete input error, got %v", input, err)
+		}
+	}
+
+	// リーダマクロの関数から戻ったストリームは使えない
+	for _, input := range []string{
+		`(read-char *saved*)`,
+		`(read *saved*)`,
+		`(peek-char nil *saved*)`,
+		`(read-char 1)`,
+		`(peek-char 1 *saved*)`,
+		`(read)`,
+	} {
+		expr, err := newTestParser(input, env).Parse()
+		if err != nil {
+			t.Fatalf("parse error: %v", err)
+		}
+		if _, err := Eval(expr, env); err == nil {
+			t.Errorf("%s: expected error", input)
+		}
+	}
+}
+
+func TestEval_CopyReadtable(t *testing.T) {
+	env := NewGlobalEnvironment()
+
+	// コピーに登録しても*readtable*は変わらない
+	// *readtable*をletで束縛したときは、束縛したリードテーブルに登録する
+	for _, input := range []string{
+		`((lambda (rt) (set-macro-character "!" (lambda (stream char) (read stream)) nil rt)) (copy-readtable))`,
+		`(let ((*readtable* (copy-readtable nil))) (set-macro-character "!" (lambda (stream char) (read stream))))`,
+		`(copy-readtable nil)`,
+		`((lambda (from to) (copy-readtable from to)) (copy-readtable) (copy-readtable))`,
+	} {
+		expr, err := newTestParser(input, env).Parse()
+		if err != nil {
+			t.Fatalf("parse error: %v", err)
+		}
+		if _, err := Eval(expr, env); err != nil {
+			t.Fatalf("eval error: %v", err)
+		}
+	}
+
+	parser := newTestParser("!x", env)
+	parser.SetReadtable(env.Readtable())
+	expr, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	if expr.String() != "!x" {
+		t.Errorf("*readtable* changed: got %s", expr.String())
+	}
+
+	for _, input := range []string{
+		`(set-macro-character "ab" (lambda (x) x))`,
+		`(set-macro-character 1 (lambda (x) x))`,
+		`(set-macro-character "(" (lambda (x) x))`,
+		`(set-dispatch-macro-character "#" 1 (lambda (x) x))`,
+		`(copy-readtable 1)`,
+	} {
+		expr, err := newTestParser(input, env).Parse()
+		if err != nil {
+			t.Fatalf("parse error: %v", err)
+		}
+		if _, err := Eval(expr, env); err == nil {
+			t.Errorf("%s: expected error", input)
+		}
+	}
+}
+
+// envと同じシンボルを読むParser
+func newTestParser(input string, env *Environment) *reader.Parser {
+	p := reader.NewParser(input)
+	p.SetPackage(env.Package())
+	return p
+}
+
+// inputの式を順に評価して、結果の文字列表現を返す
+// 式ごとに読むので、前の式で定義したパッケージやリーダマクロを次の式で使える
+func evalForms(t *testing.T, env *Environment, input string) []string {
+	t.Helper()
+	r := reader.NewReader(strings.NewReader(input))
+	r.SetPackage(env.Package())
+
+	var results []string
+	for {
+		r.SetReadtable(env.Readtable())
+		form, err := r.Read()
+		if err == io.EOF {
+			return results
+		}
+		if err != nil {
+			t.Fatalf("read error: %v", err)
+		}
+		result, err := Eval(form.Expr, env)
+		if err != nil {
+			t.Fatalf("eval error: %v", err)
+		}
+		results = append(results, result.String())
+	}
+}
+
+func TestEval_Packages(t *testing.T) {
+	input := `:test
+(keywordp :test)
+(keywordp 'test)
+(cl:+ 1 2)
+(common-lisp:funcall (lambda (x) x) 'cl:quote)
+(make-package "eval-test" :nicknames '("et") :use '("common-lisp"))
+(defun et::square (x) (* x x))
+(eval-test::square 3)
+(export 'et::square "et")
+(et:square 4)
+'et::square
+(symbol-name 'et::square)
+(package-name (symbol-package :test))
+(package-name (find-package 'cl))
+(find-package "no-such-package")
+(intern "square" "eval-test")`
+
+	env := NewGlobalEnvironment()
+	results := evalForms(t, env, input)
+
+	expected := []string{":test", "T", "NIL", "3", "quote", "#<PACKAGE eval-test>", "eval-test::square",
+		"9", "T", "16", "eval-test:square", `"square"`, `"keyword"`, `"common-lisp"`, "NIL", "eval-test:square"}
+	if strings.Join(results, " ") != strings.Join(expected, " ") {
+		t.Errorf("got %v, want %v", results, expected)
+	}
+
+	// 別のパッケージのシンボルは、同じ名前でも別の束縛になる
+	expr, err := newTestParser("(square 2)", env).Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	if _, err := Eval(expr, env); err == nil {
+		t.Errorf("expected square to be undefined in common-lisp-user")
+	}
+}
+
+func TestEval_Symbols(t *testing.T) {
+	input := `(eq 'abc 'abc)
+(eq 'abc 'abd)
+(eq 'funcall 'cl:funcall)
+(eq 'x 'cl-user::x)
+(eq '(a) '(a))
+(eq #'+ #'+)
+(symbol-plist 'color)
+(get 'color 'red)
+(get 'color 'red 'none)
+(symbol-function '+)
+(boundp 'not-bound-anywhere)
+(boundp '*readtable*)
+(symbolp 'a)
+(symbolp "a")`
+
+	env := NewGlobalEnvironment()
+	results := evalForms(t, env, input)
+
+	expected := []string{"T", "NIL", "T", "T", "NIL", "T", "NIL", "NIL", "none",
+		"#<BUILTIN +>", "NIL", "T", "T", "NIL"}
+	if strings.Join(results, " ") != strings.Join(expected, " ") {
+		t.Errorf("got %v, want %v", results, expected)
+	}
+
+	// 同じ名前を別々に読んでも同じシンボル
+	a, _ := newTestParser("color", env).Parse()
+	b, _ := newTestParser("color", env).Parse()
+	if a != b {
+		t.Fatalf("expected the same symbol object, got %p and %p", a, b)
+	}
+
+	// 属性リストはGoからも操作できる
+	sym := a.(*types.Symbol)
+	sym.Put(env.Packages().Intern("red"), types.Fixnum{Value: 255})
+	sym.Put(env.Packages().Intern("green"), types.Fixnum{Value: 0})
+	sym.Put(env.Packages().Intern("red"), types.Fixnum{Value: 128})
+	results = evalForms(t, env, `(symbol-plist 'color)
+(get 'color 'red)
+(remprop 'color 'red)
+(remprop 'color 'red)
+(symbol-plist 'color)`)
+
+	expected = []string{"(green 0 red 128)", "128", "T", "NIL", "(green 0)"}
+	if strings.Join(results, " ") != strings.Join(expected, " ") {
+		t.Errorf("got %v, want %v", results, expected)
+	}
+}
+
+func TestEval_Characters(t *testing.T) {
+	input := `#\a
+(characterp #\a)
+(characterp "a")
+(char "あいう" 1)
+(char-code #\A)
+(code-char 12354)
+(code-char 55296)
+(char-upcase #\a)
+(char-downcase #\Space)
+(alpha-char-p #\あ)
+(alpha-char-p #\1)
+(digit-char-p #\7)
+(digit-char-p #\f 16)
+(digit-char-p #\g 16)
+(upper-case-p #\A)
+(char-name #\Newline)
+(char= #\a #\a #\a)
+(char= #\a #\A)
+(char-equal #\a #\A)
+(char< #\a #\b #\c)
+(char< #\a #\c #\b)
+(eq #\x #\x)`
+
+	env := NewGlobalEnvironment()
+	results := evalForms(t, env, input)
+
+	expected := []string{`#\a`, "T", "NIL", `#\い`, "65", `#\あ`, "NIL", `#\A`, `#\Space`, "T", "NIL",
+		"7", "15", "NIL", "T", `"Newline"`, "T", "NIL", "T", "T", "NIL", "T"}
+	if strings.Join(results, " ") != strings.Join(expected, " ") {
+		t.Errorf("got %v, want %v", results, expected)
+	}
+
+	for _, input := range []string{`(char "abc" 3)`, `(char-code "a")`, `(char= #\a 1)`} {
+		expr, err := newTestParser(input, env).Parse()
+		if err != nil {
+			t.Fatalf("parse error: %v", err)
+		}
+		if _, err := Eval(expr, env); err == nil {
+			t.Errorf("%s: expected error", input)
+		}
+	}
+}
+
+func TestEval_Arrays(t *testing.T) {
+	input := `#(1 2 3)
+(vector 1 (+ 1 1) "three")
+(aref #(a b c) 1)
+(make-array 3)
+(make-array '(2 3) :initial-element 0)
+(aref (make-array '(2 2) :initial-contents '((1 2) (3 4))) 1 0)
+(array-dimensions #2A((1 2 3) (4 5 6)))
+(array-rank #2A((1 2 3) (4 5 6)))
+(array-total-size #2A((1 2 3) (4 5 6)))
+(vectorp #(1))
+(vectorp #2A((1)))
+(arrayp #2A((1)))
+(length #(1 2 3))
+(length '(1 2))
+(length "あいう")
+(defun make-buffer () (make-array 0 :adjustable t :fill-pointer 0))
+(defun push-both (v a b) ((lambda (i j) v) (vector-push-extend a v) (vector-push-extend b v)))
+(push-both (make-buffer) 'a 'b)
+(push-both (push-both (make-buffer) 1 2) 3 4)
+(vector-push-extend 'a (make-buffer))
+((lambda (v) ((lambda (i j) (vector-push 'z v)) (vector-push 'x v) (vector-push 'y v))) (make-array 2 :fill-pointer 0))
+((lambda (v) ((lambda (i) (vector-pop v)) (vector-push 'x v))) (make-array 2 :fill-pointer 0))
+((lambda (v) (fill-pointer v)) (make-array 5 :fill-pointer 2))
+(make-array 5 :fill-pointer 2 :initial-element 0)
+(make-array '(2 0))
+(make-array '(0 0))
+#2A()
+(make-array '(0 2))
+(array-dimensions (make-array '(0 2 3) :initial-contents '()))`
+
+	env := NewGlobalEnvironment()
+	results := evalForms(t, env, input)
+
+	expected := []string{"#(1 2 3)", `#(1 2 "three")`, "b", "#(NIL NIL NIL)", "#2A((0 0 0) (0 0 0))", "3",
+		"(2 3)", "2", "6", "T", "NIL", "T", "3", "2", "3", "make-buffer",
+		"push-both", "#(a b)", "#(1 2 3 4)", "0", "NIL", "x", "2", "#(0 0)",
+		"#2A(() ())", "#2A()", "#2A()", "#<ARRAY (0 2)>", "(0 2 3)"}
+	if strings.Join(results, " ") != strings.Join(expected, " ") {
+		t.Errorf("got %v, want %v", results, expected)
+	}
+
+	for _, input := range []string{
+		`(aref #(1 2) 2)`,
+		`(aref #2A((1 2)) 0)`,
+		`(make-array '(2 2) :initial-contents '((1 2) (3)))`,
+		`(make-array 2 :bogus 1)`,
+		`(make-array '(4294967296 4294967296))`,
+		`(make-array 100000000000)`,
+		`(make-array '(0 100000000000))`,
+		`(vector-push-extend 1 (make-array 0 :fill-pointer 0))`,
+		`(vector-pop (make-array 0 :fill-pointer 0))`,
+		`(fill-pointer #(1 2))`,
+	} {
+		expr, err := newTestParser(input, env).Parse()
+		if err != nil {
+			t.Fatalf("parse error: %v", err)
+		}
+		if _, err := Eval(expr, env); err == nil {
+			t.Errorf("%s: expected error", input)
+		}
+	}
+}
+
+func TestEval_HashTables(t *testing.T) {
+	env := NewGlobalEnvironment()
+
+	// 中身はGoから入れておく
+	tables := map[string]*types.HashTable{}
+	for _, test := range []types.HashTest{types.TestEq, types.TestEql, types.TestEqual, types.TestEqualp} {
+		table := types.NewHashTable(test)
+		table.Put(types.Fixnum{Value: 1}, types.String{Value: "one"})
+		table.Put(types.Float{Value: 1.5}, types.String{Value: "float"})
+		table.Put(env.Packages().Intern("sym"), types.String{Value: "symbol"})
+		table.Put(types.String{Value: "Key"}, types.String{Value: "string"})
+		big, _ := newTestParser("100000000000000000000", env).Parse()
+		table.Put(big, types.String{Value: "bignum"})
+		list, _ := newTestParser("(a (b 2))", env).Parse()
+		table.Put(list, types.String{Value: "list"})
+		table.Put(types.Character{Value: 'x'}, types.String{Value: "char"})
+		env.Set(env.Packages().Intern(test.String()+"-table"), table)
+		tables[test.String()] = table
+	}
+
+	input := `(make-hash-table)
+(make-hash-table :test 'equal)
+(make-hash-table :test #'equalp)
+(hash-table-count equal-table)
+(hash-table-test eq-table)
+(gethash 1 eql-table)
+(gethash 'sym eq-table)
+(gethash "Key" eql-table)
+(gethash 100000000000000000000 eq-table)
+(gethash 100000000000000000000 eql-table)
+(gethash '(a (b 2)) eql-table)
+(gethash '(a (b 2)) equal-table)
+(gethash '(a (b 2.0)) equal-table)
+(gethash '(a (b 2.0)) equalp-table)
+(gethash 1.0 eql-table)
+(gethash 1.0 equalp-table)
+(gethash 3/2 equalp-table)
+(gethash "KEY" equal-table)
+(gethash "KEY" equalp-table)
+(gethash #\X equalp-table)
+(gethash 'missing equal-table)
+(gethash 'missing equal-table 'default)
+(remhash 'sym equal-table)
+(remhash 'sym equal-table)
+(hash-table-count equal-table)
+(maphash (lambda (k v) (remhash k equal-table)) equal-table)
+(hash-table-count equal-table)
+(hash-table-count (clrhash eql-table))
+(hash-table-p eq-table)
+(hash-table-p '(a))
+(eql 1 1)
+(eql 1 1.0)
+(eql 100000000000000000000 100000000000000000000)
+(equal '(1 (2 "x")) '(1 (2 "x")))
+(equal #(1) #(1))
+(equalp #(1 "A") #(1.0 "a"))
+(equalp '(#\a) '(#\A))
+(equal #'+ #'+)
+(equalp 9007199254740993 9007199254740992.0)
+(equalp 1/2 0.5)
+((lambda (tbl) (setf (gethash '(a b c 1) tbl) 1) (setf (gethash '(a b c 2) tbl) 2) (vector (gethash '(a b c 1) tbl) (gethash '(a b c 2) tbl) (hash-table-count tbl))) (make-hash-table :test 'equal))
+((lambda (tbl l) (setf (cdr (cdr l)) l) (setf (gethash l tbl) 'circular) (gethash l tbl)) (make-hash-table :test 'equal) (list 1 2))
+(flet ((try (test) (let ((tbl (make-hash-table :test test))) (setf (gethash #'car tbl) 1) (setf (gethash #'car tbl) 2) (setf (gethash (lambda (x) x) tbl) 3) (list (gethash #'car tbl) (gethash #'cdr tbl) (hash-table-count tbl))))) (list (try 'eq) (try 'eql) (try 'equal) (try 'equalp)))`
+
+	results := evalForms(t, env, input)
+	expected := []string{"#<HASH-TABLE :test eql :count 0>", "#<HASH-TABLE :test equal :count 0>",
+		"#<HASH-TABLE :test equalp :count 0>", "7", "eq",
+		// gethashは値と、キーがあったかどうかの2つの値を返す
+		"\"one\"\nT", "\"symbol\"\nT", "\"string\"\nT", "NIL\nNIL", "\"bignum\"\nT", "NIL\nNIL",
+		"\"list\"\nT", "NIL\nNIL", "\"list\"\nT", "NIL\nNIL", "\"one\"\nT", "\"float\"\nT",
+		"NIL\nNIL", "\"string\"\nT", "\"char\"\nT", "NIL\nNIL", "default\nNIL",
+		"T", "NIL", "6", "NIL", "0", "0", "T", "NIL",
+		"T", "NIL", "T", "T", "NIL", "T", "T", "T", "NIL", "T",
+		"#(1 2 2)", "circular\nT", "((2 NIL 2) (2 NIL 2) (2 NIL 2) (2 NIL 2))"}
+	if strings.Join(results, " ") != strings.Join(expected, " ") {
+		t.Errorf("got %v, want %v", results, expected)
+	}
+
+	// maphashは入れた順にたどる
+	var keys []string
+	err := tables["eq"].Range(func(key, value types.Expr) error {
+		keys = append(keys, key.String())
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `1 1.5 sym "Key" 100000000000000000000 (a (b 2)) #\x`
+	if strings.Join(keys, " ") != want {
+		t.Errorf("got %v, want %s", keys, want)
+	}
+
+	for _, input := range []string{
+		`(make-hash-table :test 'car)`,
+		`(make-hash-table :test)`,
+		`(gethash 1 '(1))`,
+		`(maphash (lambda (k) k) eq-table)`,
+	} {
+		expr, err := newTestParser(input, env).Parse()
+		if err != nil {
+			t.Fatalf("parse error: %v", err)
+		}
+		if _, err := Eval(expr, env); err == nil {
+			t.Errorf("%s: expected error", input)
+		}
+	}
+}
+
+func TestEval_Structures(t *testing.T) {
+	env := NewGlobalEnvironment()
+
+	input := `(defstruct point x (y 0))
+(make-point :x 1 :y 2)
+(make-point :x 1)
+(make-point)
+(point-x (make-point :x 3 :y 4))
+(point-y (make-point :x 3))
+(point-p (make-point))
+(point-p '(1 2))
+(copy-point (make-point :x 5))
+(defstruct (point3d (:include point)) "3次元の点" (z (+ 1 2)))
+(make-point3d :x 1 :z 9)
+(point3d-z (make-point3d))
+(point-x (make-point3d :x 7))
+(point-p (make-point3d))
+(point3d-p (make-point))
+(defstruct (node (:conc-name n-) (:constructor new-node) (:predicate nil) (:copier nil)) (value nil :type integer :read-only t) next)
+(n-value (new-node :value 1 :next (new-node :value 2)))
+(equalp (make-point :x 1.0) (make-point :x 1))
+(equal (make-point :x 1) (make-point :x 1))
+'#S(point :x 1 :y 2)
+(point-y '#S(point :x 1))
+(equalp (make-point :x 1 :y 2) #S(point :y 2 :x 1))
+#s(point3d z 5)
+(defstruct (point4d (:include point (x 42) (y 1 :read-only t))) w)
+(make-point4d :w 2)
+(point-x (make-point4d))
+(defstruct (hidden (:constructor nil)) a)`
+
+	results := evalForms(t, env, input)
+	expected := []string{"point", "#S(point :x 1 :y 2)", "#S(point :x 1 :y 0)", "#S(point :x NIL :y 0)",
+		"3", "0", "T", "NIL", "#S(point :x 5 :y 0)",
+		"point3d", "#S(point3d :x 1 :y 0 :z 9)", "3", "7", "T", "NIL",
+		"node", "1", "T", "NIL",
+		"#S(point :x 1 :y 2)", "0", "T", "#S(point3d :x NIL :y 0 :z 5)",
+		"point4d", "#S(point4d :x 42 :y 1 :w 2)", "42", "hidden"}
+	if strings.Join(results, " ") != strings.Join(expected, " ") {
+		t.Errorf("got %v, want %v", results, expected)
+	}
+
+	for _, input := range []string{
+		`(defstruct)`,
+		`(defstruct (bad (:include missing)))`,
+		`(defstruct (bad (:unknown 1)))`,
+		`(defstruct bad x x)`,
+		`(make-point :z 1)`,
+		`(point-x '(1 2))`,
+		`(point3d-z (make-point))`,
+		`(make-node)`,
+		`(node-p (new-node))`,
+		`(defstruct (bad (:include point (z 1))))`,
+		`(defstruct (bad (:conc-name a b)))`,
+		`(setf (point4d-y (make-point4d)) 2)`,
+	} {
+		expr, err := newTestParser(input, env).Parse()
+		if err != nil {
+			t.Fatalf("parse error: %v", err)
+		}
+		if _, err := Eval(expr, env); err == nil {
+			t.Errorf("%s: expected error", input)
+		}
+	}
+
+	// #Sはコンストラクタのある構造体だけ読める
+	for _, input := range []string{
+		`#S(missing)`,
+		`#S(point :x)`,
+		`#S(1)`,
+		`#S(point "x" 1)`,
+		`#S(point :z 1)`,
+		`#S(hidden)`,
+	} {
+		p := newTestParser(input, env)
+		p.SetReadtable(env.Readtable())
+		if _, err := p.Parse(); err == nil {
+			t.Errorf("%s: expected read error", input)
+		}
+	}
+}
+
+func TestEval_MultipleValues(t *testing.T) {
+	env := NewGlobalEnvironment()
+
+	input := `(multiple-value-list (values 1 2 3))
+(multiple-value-list (values))
+(multiple-value-list 1)
+(multiple-value-list (values-list '(a b)))
+(+ (values 1 2) 10)
+(vector (values 1 2) (values))
+(multiple-value-bind (a b c) (values 1 2) (vector a b c))
+(multiple-value-bind (a) (values 1 2) a)
+(multiple-value-bind () (values 1 2))
+(multiple-value-call #'+ (values 1 2) 3 (values 4 5))
+(multiple-value-call #'vector (values) (values 'a 'b))
+(nth-value 1 (values 'a 'b))
+(nth-value 2 (values 'a 'b))
+(defun two () (values 1 2))
+(defun pass () (if t (two) nil))
+(multiple-value-list (pass))
+(multiple-value-list (funcall #'two))
+(defun drop () (+ (two) 0))
+(multiple-value-list (drop))
+(if (values nil t) 'yes 'no)
+(multiple-value-list (gethash 'x (make-hash-table)))`
+
+	results := evalForms(t, env, input)
+	expected := []string{"(1 2 3)", "NIL", "(1)", "(a b)", "11", "#(1 NIL)",
+		"#(1 2 NIL)", "1", "NIL", "15", "#(a b)", "b", "NIL",
+		"two", "pass", "(1 2)", "(1 2)", "drop", "(1)", "no", "(NIL NIL)"}
+	if strings.Join(results, " ") != strings.Join(expected, " ") {
+		t.Errorf("got %v, want %v", results, expected)
+	}
+
+	for _, input := range []string{
+		`(multiple-value-bind (a 1) (values 1 2) a)`,
+		`(multiple-value-bind (a))`,
+		`(multiple-value-list)`,
+		`(multiple-value-call)`,
+		`(nth-value -1 (values 1))`,
+		`(nth-value 'a (values 1))`,
+		`(values-list 1)`,
+	} {
+		expr, err := newTestParser(input, env).Parse()
+		if err != nil {
+			t.Fatalf("parse error: %v", err)
+		}
+		if _, err := Eval(expr, env); err == nil {
+			t.Errorf("%s: expected error", input)
+		}
+	}
+}
+
+func TestEval_Bodies(t *testing.T) {
+	env := NewGlobalEnvironment()
+
+	input := `(progn)
+(progn 1 2 3)
+(multiple-value-list (progn 1 (values 2 3)))
+(defun push-twice (v x) (vector-push x v) (vector-push x v) v)
+(push-twice (make-array 3 :fill-pointer 0) 'a)
+(defun documented (x) "xを返す" (declare (ignore y)) x)
+(documented 5)
+(defun doc-only () "ドキュメントではなく値")
+(doc-only)
+(defun empty ())
+(empty)
+((lambda (x) (declare (type integer x)) (+ x 1) (* x 2)) 4)
+(multiple-value-bind (a b) (values 1 2) (declare (ignore a)) b)`
+
+	results := evalForms(t, env, input)
+	expected := []string{"NIL", "3", "(2 3)", "push-twice", "#(a a)",
+		"documented", "5", "doc-only", `"ドキュメントではなく値"`, "empty", "NIL", "8", "2"}
+	if strings.Join(results, " ") != strings.Join(expected, " ") {
+		t.Errorf("got %v, want %v", results, expected)
+	}
+
+	lambda := env.Packages().Intern("documented").Function
+	if doc := lambda.(*Lambda).Doc; doc != "xを返す" {
+		t.Errorf("got doc %q, want %q", doc, "xを返す")
+	}
+
+	for _, input := range []string{
+		`(progn 1 . 2)`,
+		`(declare (ignore x))`,
+		`(progn (declare (ignore x)) 1)`,
+		`((lambda (x) x (declare (ignore x))) 1)`,
+		`(lambda (x) . 1)`,
+	} {
+		expr, err := newTestParser(input, env).Parse()
+		if err != nil {
+			t.Fatalf("parse error: %v", err)
+		}
+		if _, err := Eval(expr, env); err == nil {
+			t.Errorf("%s: expected error", input)
+		}
+	}
+}
+
+func TestEval_LocalBindings(t *testing.T) {
+	env := NewGlobalEnvironment()
+
+	input := `(let ((x 1) (y 2)) (+ x y))
+(let (x (y)) (vector x y))
+(let () 5)
+(let ((x 1)) (let ((x 2) (y x)) (vector x y)))
+(let* ((x 1) (y (+ x 1))) (vector x y))
+(let ((x 1)) (declare (ignore x)) (multiple-value-list (values 1 2)))
+(let ((f (let ((n 10)) (lambda (x) (+ x n))))) (funcall f 5))
+(flet ((double (x) (* x 2)) (inc (x) (+ x 1))) (double (inc 3)))
+(defun outer (x) (* x 100))
+(flet ((outer (x) (outer (+ x 1)))) (outer 1))
+(labels ((fact (n) (if (= n 0) 1 (* n (fact (- n 1)))))) (fact 20))
+(labels ((my-even (n) (if (= n 0) t (my-odd (- n 1))))
+         (my-odd (n) (if (= n 0) nil (my-even (- n 1)))))
+  (vector (my-even 10) (my-odd 7) (my-even 3)))`
+
+	results := evalForms(t, env, input)
+	expected := []string{"3", "#(NIL NIL)", "5", "#(2 1)", "#(1 2)", "(1 2)", "15", "8",
+		"outer", "200", "2432902008176640000", "#(T T NIL)"}
+	if strings.Join(results, " ") != strings.Join(expected, " ") {
+		t.Errorf("got %v, want %v", results, expected)
+	}
+
+	for _, input := range []string{
+		`(let)`,
+		`(let x x)`,
+		`(let ((1 2)) 1)`,
+		`(let ((:k 2)) 1)`,
+		`(let ((x 1 2)) x)`,
+		`(let ((x 1) (y x)) y)`,
+		`(flet ((f (n) (if (= n 0) 0 (f (- n 1))))) (f 1))`,
+		`(flet ((1 (x) x)) 1)`,
+		`(labels (f) 1)`,
+	} {
+		expr, err := newTestParser(input, env).Parse()
+		if err != nil {
+			t.Fatalf("parse error: %v", err)
+		}
+		if _, err := Eval(expr, env); err == nil {
+			t.Errorf("%s: expected error", input)
+		}
+	}
+}
+
+func TestEval_Lists(t *testing.T) {
+	env := NewGlobalEnvironment()
+
+	input := `(cons 1 2)
+(cons 1 nil)
+(list 1 (list 2 3) "a")
+(list)
+(car '(a b))
+(cdr '(a b))
+(car nil)
+(cdr nil)
+(nth 1 '(a b c))
+(nth 5 '(a b c))`
+
+	results := evalForms(t, env, input)
+	expected := []string{"(1 . 2)", "(1)", `(1 (2 3) "a")`, "NIL", "a", "(b)", "NIL", "NIL", "b", "NIL"}
+	if strings.Join(results, " ") != strings.Join(expected, " ") {
+		t.Errorf("got %v, want %v", results, expected)
+	}
+
+	for _, input := range []string{
+		`(car 1)`,
+		`(cdr '(a) '(b))`,
+		`(cons 1)`,
+		`(nth -1 '(a))`,
+		`(nth 1 '(a . b))`,
+	} {
+		expr, err := newTestParser(input, env).Parse()
+		if err != nil {
+			t.Fatalf("parse error: %v", err)
+		}
+		if _, err := Eval(expr, env); err == nil {
+			t.Errorf("%s: expected error", input)
+		}
+	}
+}
+
+func TestEval_Setf(t *testing.T) {
+	env := NewGlobalEnvironment()
+
+	input := `(setq counter 0)
+(defun make-counter () (let ((n 0)) (lambda () (setq n (+ n 1)))))
+(setq c (make-counter))
+(funcall c)
+(funcall c)
+(let ((x 1)) (setq x 2 counter x) (vector x counter))
+(setq)
+(setf cell (list 1 2 3))
+(setf (car cell) 'a (cdr (cdr cell)) '(c))
+cell
+(setf (nth 1 cell) 'b)
+cell
+(setf table (make-hash-table))
+(setf (gethash 'k table) 1)
+(incf (gethash 'k table) 10)
+(incf (gethash 'missing table 0))
+(multiple-value-list (gethash 'missing table))
+(setf v (make-array '(2 2) :initial-element 0))
+(setf (aref v 1 0) 5)
+(decf (aref v 1 0) 2)
+v
+(setf (symbol-value 'global) 'g)
+global
+(setf (get 'sym 'color) 'red)
+(get 'sym 'color)
+(setf stack nil)
+(push 1 stack)
+(push 2 stack)
+(pop stack)
+stack
+(pop stack)
+(pop stack)
+(incf counter)
+(decf counter 1/2)
+(defstruct point x (y 0 :read-only t))
+(setf p (make-point :x 1))
+(setf (point-x p) 10)
+(incf (point-x p))
+p
+(defun (setf middle) (value list) (setf (nth 1 list) value))
+(setf (middle cell) 'm)
+cell
+(defun first-of (list) (car list))
+(defun set-first-of (list value) (setf (car list) value))
+(defsetf first-of set-first-of)
+(setf (first-of cell) 'f)
+(defun second-of (list) (nth 1 list))
+(defsetf second-of (l) (new) (list 'setf (list 'nth 1 l) new))
+(setf (second-of cell) '(x y))
+(incf (second-of (list 0 5)))
+cell
+(setf index 0)
+(defun next-index () (setq index (+ index 1)))
+(setf w (vector 10 20 30))
+(incf (aref w (next-index)) 100)
+index
+w`
+
+	results := evalForms(t, env, input)
+	expected := []string{"0", "make-counter", "#<FUNCTION>", "1", "2", "#(2 2)", "NIL",
+		"(1 2 3)", "(c)", "(a 2 c)", "b", "(a b c)",
+		"#<HASH-TABLE :test eql :count 0>", "1", "11", "1", "(1 T)",
+		"#2A((0 0) (0 0))", "5", "3", "#2A((0 0) (3 0))", "g", "g", "red", "red",
+		"NIL", "(1)", "(2 1)", "2", "(1)", "1", "NIL", "3", "5/2",
+		"point", "#S(point :x 1 :y 0)", "10", "11", "#S(point :x 11 :y 0)",
+		"(setf middle)", "m", "(a m c)",
+		"first-of", "set-first-of", "first-of", "f",
+		"second-of", "second-of", "(x y)", "6", "(f (x y) c)",
+		"0", "next-index", "#(10 20 30)", "120", "1", "#(10 120 30)"}
+	if strings.Join(results, " ") != strings.Join(expected, " ") {
+		t.Errorf("got %v, want %v", results, expected)
+	}
+
+	for _, input := range []string{
+		`(setq x)`,
+		`(setq :k 1)`,
+		`(setq 1 2)`,
+		`(setf (point-y p) 1)`,
+		`(setf (point-x '(1)) 1)`,
+		`(setf (unknown-accessor cell) 1)`,
+		`(setf (nth 10 cell) 1)`,
+		`(setf (car nil) 1)`,
+		`(setf 1 2)`,
+		`(incf cell)`,
+		`(pop counter)`,
+		`(push 1)`,
+		`(defsetf second-of (list) (a b) nil)`,
+	} {
+		expr, err := newTestParser(input, env).Parse()
+		if err != nil {
+			t.Fatalf("parse error: %v", err)
+		}
+		if _, err := Eval(expr, env); err == nil {
+			t.Errorf("%s: expected error", input)
+		}
+	}
+}
+
+func TestEval_SpecialVariables(t *testing.T) {
+	env := NewGlobalEnvironment()
+
+	input := `(defvar *depth* 1 "深さ")
+(defvar *depth* 100)
+*depth*
+(defvar *unset*)
+(boundp '*unset*)
+(defparameter *base* 10)
+(defparameter *base* 16)
+*base*
+(defun depth () *depth*)
+(let ((*depth* 2)) (depth))
+(depth)
+(let* ((*depth* 3) (x (depth))) x)
+(defun call-with-depth (*depth*) (depth))
+(call-with-depth 4)
+(depth)
+(let ((*depth* 5)) (setq *depth* 6) (depth))
+*depth*
+(multiple-value-bind (*depth*) (values 7) (depth))
+(let ((f (let ((*depth* 8)) (lambda () *depth*)))) (funcall f))
+(defconstant +limit+ 3)
+(defconstant +limit+ 3)
++limit+
+(setq *unset* 'now)`
+
+	results := evalForms(t, env, input)
+	expected := []string{"*depth*", "*depth*", "1", "*unset*", "NIL", "*base*", "*base*", "16",
+		"depth", "2", "1", "3", "call-with-depth", "4", "1", "6", "1", "7", "1",
+		"+limit+", "+limit+", "3", "now"}
+	if strings.Join(results, " ") != strings.Join(expected, " ") {
+		t.Errorf("got %v, want %v", results, expected)
+	}
+
+	// 別のグローバル環境では、*unset*も*depth*もまだ宣言されていない
+	results = evalForms(t, NewGlobalEnvironment(), `(boundp '*unset*)
+(boundp '*depth*)
+(defvar *unset*)
+(boundp '*unset*)`)
+	if got := strings.Join(results, " "); got != "NIL NIL *unset* NIL" {
+		t.Errorf("fresh environment: got %v", results)
+	}
+
+	// エラーで抜けても元の値に戻る
+	for _, input := range []string{
+		`(let ((*depth* 9)) (car 1))`,
+		`(call-with-depth (car 1))`,
+		`((lambda (*depth*) (car 1)) 9)`,
+	} {
+		expr, err := newTestParser(input, env).Parse()
+		if err != nil {
+			t.Fatalf("parse error: %v", err)
+		}
+		if _, err := Eval(expr, env); err == nil {
+			t.Errorf("%s: expected error", input)
+		}
+		if depth := env.Packages().Intern("*depth*").Value; depth.String() != "1" {
+			t.Errorf("%s: *depth* is %v after the error, want 1", input, depth)
+		}
+	}
+
+	for _, input := range []string{
+		`(defvar)`,
+		`(defvar :k)`,
+		`(defvar x 1 2)`,
+		`(defparameter x)`,
+		`(defconstant +limit+ 4)`,
+		`(defvar +limit+ 1)`,
+		`(setq +limit+ 4)`,
+		`(setf +limit+ 4)`,
+		`(setf (symbol-value '+limit+) 4)`,
+		`(let ((+limit+ 4)) +limit+)`,
+		`((lambda (+limit+) +limit+) 4)`,
+	} {
+		expr, err := newTestParser(input, env).Parse()
+		if err != nil {
+			t.Fatalf("parse error: %v", err)
+		}
+		if _, err := Eval(expr, env); err == nil {
+			t.Errorf("%s: expected error", input)
+		}
+	}
+}
+
+func TestEval_FunctionNamespace(t *testing.T) {
+	env := NewGlobalEnvironment()
+
+	input := `(let ((list 1)) (list list))
+(defun square (x) (* x x))
+(setq square 3)
+(square square)
+#'square
+(function car)
+(funcall #'square 4)
+(funcall 'square 5)
+(apply 'square '(6))
+(apply #'+ '(1 2 3))
+(funcall (function (lambda (x) (+ x 1))) 1)
+(flet ((square (x) (+ x x))) (vector (square 3) (funcall #'square 3) (funcall 'square 3)))
+(labels ((f (n) (if (= n 0) 'done (f (- n 1))))) (funcall #'f 3))
+(fboundp 'square)
+(fboundp 'if)
+(fboundp 'no-such-function)
+(defun (setf head) (value list) (setf (car list) value))
+(funcall #'(setf head) 'z (list 1 2))
+(setf (symbol-function 'twice) (lambda (x) (* 2 x)))
+(twice 21)
+(eq (symbol-function 'car) #'car)
+(fmakunbound 'twice)
+(fboundp 'twice)
+(defstruct point x)
+(point-x (make-point :x (square 3)))
+(funcall #'point-x (funcall 'make-point :x 1))
+(equal (list #'car) (list #'car))
+(eq #'car #'cdr)
+(defvar *old-make-point* #'make-point)
+(defstruct point x y)
+(eq *old-make-point* #'make-point)
+(eql #'make-point #'make-point)`
+
+	results := evalForms(t, env, input)
+	expected := []string{"(1)", "square", "3", "9", "#<FUNCTION>", "#<BUILTIN car>",
+		"16", "25", "36", "6", "2", "#(6 6 9)", "done", "T", "T", "NIL",
+		"(setf head)", "z", "#<FUNCTION>", "42", "T", "twice", "NIL",
+		"point", "9", "1", "T", "NIL", "*old-make-point*", "point", "NIL", "T"}
+	if strings.Join(results, " ") != strings.Join(expected, " ") {
+		t.Errorf("got %v, want %v", results, expected)
+	}
+
+	for _, input := range []string{
+		`(twice 1)`,
+		`(funcall 'twice 1)`,
+		`(symbol-function 'twice)`,
+		`#'no-such-function`,
+		`#'(setf no-such-accessor)`,
+		`(function 1)`,
+		`(function car cdr)`,
+		`(1 2)`,
+		`((car) 2)`,
+		`(let ((f #'car)) (f '(1)))`,
+		`(setf (symbol-function 'x) 1)`,
+	} {
+		expr, err := newTestParser(input, env).Parse()
+		if err != nil {
+			t.Fatalf("parse error: %v", err)
+		}
+		if _, err := Eval(expr, env); err == nil {
+			t.Errorf("%s: expected error", input)
+		}
+	}
+}
+
+// グローバル環境どうしは、シンボルもパッケージも定義も共有しない
+func TestEval_IsolatedEnvironments(t *testing.T) {
+	env1 := NewGlobalEnvironment()
+	evalForms(t, env1, `(defun leaked () 42)
+(defvar *v* 7)
+(defconstant +c+ 1)
+(defstruct point x)
+(defun (setf head) (value list) (setf (car list) value))
+(setf (get 'color 'red) 255)
+(make-package "isolated")`)
+
+	env2 := NewGlobalEnvironment()
+	results := evalForms(t, env2, `(fboundp 'leaked)
+(boundp '*v*)
+(let ((+c+ 2)) +c+)
+(fboundp 'make-point)
+(symbol-plist 'color)
+(find-package "isolated")
+(make-package "isolated")`)
+
+	expected := []string{"NIL", "NIL", "2", "NIL", "NIL", "NIL", "#<PACKAGE isolated>"}
+	if strings.Join(results, " ") != strings.Join(expected, " ") {
+		t.Errorf("got %v, want %v", results, expected)
+	}
+
+	for _, input := range []string{
+		`(defstruct (point3d (:include point)) z)`,
+		`(setf (head (list 1)) 2)`,
+	} {
+		expr, err := newTestParser(input, env2).Parse()
+		if err != nil {
+			t.Fatalf("parse error: %v", err)
+		}
+		if _, err := Eval(expr, env2); err == nil {
+			t.Errorf("%s: expected error", input)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// ハッシュテーブルを扱う組み込み関数
+package eval
+
+import (
+	"fmt"
+
+	"github.com/koplec/gospl/internal/types"
+)
+
+func registerHashTableBuiltins(env *Environment) {
+	env.define("make-hash-table", &BuiltinFunc{Name: "make-hash-table", Fn: builtinMakeHashTable})
+	env.define("hash-table-p", &BuiltinFunc{Name: "hash-table-p", Fn: builtinHashTableP})
+	env.define("hash-table-count", &BuiltinFunc{Name: "hash-table-count", Fn: builtinHashTableCount})
+	env.define("hash-table-test", &BuiltinFunc{
+		Name: "hash-table-test",
+		Fn: func(args []types.Expr) (types.Expr, error) {
+			return builtinHashTableTest(env, args)
+		},
+	})
+	env.define("gethash", &BuiltinFunc{Name: "gethash", Fn: builtinGethash})
+	env.define("remhash", &BuiltinFunc{Name: "remhash", Fn: builtinRemhash})
+	env.define("clrhash", &BuiltinFunc{Name: "clrhash", Fn: builtinClrhash})
+	env.define("maphash", &BuiltinFunc{Name: "maphash", Fn: builtinMaphash})
+}
+
+// (make-hash-table &key test size)
+// testはeq eql equal equalpのどれかで、省略するとeql
+// シンボルでも関数でもよい (make-hash-table :test 'equal)
+// sizeは受け付けるだけで使わない
+func builtinMakeHashTable(args []types.Expr) (types.Expr, error) {
+	keys, err := keywordArgs("make-hash-table", args, "test", "size")
+	if err != nil {
+		return nil, err
+	}
+	test := types.TestEql
+	if t, ok := keys["test"]; ok {
+		if test, err = hashTestArg(t); err != nil {
+			return nil, err
+		}
+	}
+	return types.NewHashTable(test), nil
+}
+
+// (hash-table-p x)
+func builtinHashTableP(args []types.Expr) (types.Expr, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("hash-table-p requires exactly 1 argument")
+	}
+	_, ok := args[0].(*types.HashTable)
+	return types.Boolean{Value: ok}, nil
+}
+
+// (hash-table-count table)
+func builtinHashTableCount(args []types.Expr) (types.Expr, error) {
+	table, err := hashTableArg("hash-table-count", args)
+	if err != nil {
+		return nil, err
+	}
+	return types.Fixnum{Value: int64(table.Count())}, nil
+}
+
+// (hash-table-test table)
+// 比べ方の名前のシンボル
+func builtinHashTableTest(env *Environment, args []types.Expr) (types.Expr, error) {
+	table, err := hashTableArg("hash-table-test", args)
+	if err != nil {
+		return nil, err
+	}
+	return env.Packages().CommonLisp.Export(table.Test.String()), nil
+}
+
+// (gethash key table &optional default)
+// 値と、keyがあったかどうかの2つの値を返す
+// keyがなければdefault（省略するとNIL）とNIL
+func builtinGethash(args []types.Expr) (types.Expr, error) {
+	if len(args) < 2 || len(args) > 3 {
+		return nil, fmt.Errorf("gethash requires 2 or 3 arguments")
+	}
+	table, err := hashTableArg("gethash", args[1:2])
+	if err != nil {
+		return nil, err
+	}
+	if value, ok := table.Get(args[0]); ok {
+		return types.NewValues(value, types.Boolean{Value: true}), nil
+	}
+	var value types.Expr = &types.Nil{}
+	if len(args) == 3 {
+		value = args[2]
+	}
+	return types.NewValues(value, &types.Nil{}), nil
+}
+
+// (remhash key table)
+// 取り除いたときはT、なかったときはNIL
+func builtinRemhash(args []types.Expr) (types.Expr, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("remhash requires exactly 2 arguments")
+	}
+	table, err := hashTableArg("remhash", args[1:])
+	if err != nil {
+		return nil, err
+	}
+	return types.Boolean{Value: table.Remove(args[0])}, nil
+}
+
+// (clrhash table)
+// 空にしたテーブルを返す
+func builtinClrhash(args []types.Expr) (types.Expr, error) {
+	table, err := hashTableArg("clrhash", args)
+	if err != nil {
+		return nil, err
+	}
+	table.Clear()
+	return table, nil
+}
+
+// (maphash function table)
+// 入れた順に、キーと値を引数にfunctionを呼ぶ
+func builtinMaphash(args []types.Expr) (types.Expr, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("maphash requires exactly 2 arguments")
+	}
+	table, err := hashTableArg("maphash", args[1:])
+	if err != nil {
+		return nil, err
+	}
+	err = table.Range(func(key, value types.Expr) error {
+		_, err := apply(args[0], []types.Expr{key, value})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &types.Nil{}, nil
+}
+
+// make-hash-tableの:testの値
+// eqのようなシンボルか、eqの関数
+func hashTestArg(expr types.Expr) (types.HashTest, error) {
+	var name string
+	switch t := expr.(type) {
+	case *types.Symbol:
+		if isCommonLisp(t) {
+			name = t.Name
+		}
+	case *BuiltinFunc:
+		name = t.Name
+	}
+	test, ok := types.HashTestByName(name)
+	if !ok {
+		return 0, fmt.Errorf("make-hash-table: :test must be eq, eql, equal or equalp, got %v", expr)
+	}
+	return test, nil
+}
+
+// 引数がハッシュテーブル1つであることを確認して返す
+func hashTableArg(fn string, args []types.Expr) (*types.HashTable, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("%s requires exactly 1 argument", fn)
+	}
+	table, ok := args[0].(*types.HashTable)
+	if !ok {
+		return nil, fmt.Errorf("%s: not a hash table: %v", fn, args[0])
+	}
+	return table, nil
+}
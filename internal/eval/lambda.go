@@ -5,8 +5,9 @@ package eval
 import "github.com/koplec/gospl/internal/types"
 
 type Lambda struct {
-	Params []string   //仮引数のリスト
-	Body   types.Expr //関数本体はS式
+	Params []*types.Symbol //仮引数のリスト
+	Body   []types.Expr    //関数本体の式、順に評価して最後の式の値を返す
+	Doc    string          //ドキュメント文字列、なければ空
 	Env    *Environment
 }
 
@@ -0,0 +1,166 @@
+// 局所的な束縛
+package eval
+
+import (
+	"fmt"
+
+	"github.com/koplec/gospl/internal/types"
+)
+
+// (let (binding*) declaration* body*)
+// bindingは var か (var) か (var init)
+// initはすべて外側の環境で評価してから、新しい環境で束縛する
+// initを省略した変数はNIL
+// defvarなどで宣言したスペシャル変数は動的に束縛し、letを抜けるときに元の値に戻す
+func evalLet(args types.Expr, env *Environment) (types.Expr, error) {
+	bindings, body, err := parseLetForm(SpecialFormLet, args)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]types.Expr, len(bindings))
+	for i, b := range bindings {
+		if values[i], err = evalPrimary(b.init, env); err != nil {
+			return nil, err
+		}
+	}
+
+	newEnv := NewEnvironment(env)
+	var dyn dynamicBindings
+	defer dyn.restore()
+	for i, b := range bindings {
+		if err := newEnv.bind(b.name, values[i], &dyn); err != nil {
+			return nil, fmt.Errorf("%s: %w", SpecialFormLet, err)
+		}
+	}
+	return evalBody(body, newEnv)
+}
+
+// (let* (binding*) declaration* body*)
+// letと違い、initは前の変数を束縛した後の環境で評価する
+func evalLetStar(args types.Expr, env *Environment) (types.Expr, error) {
+	bindings, body, err := parseLetForm(SpecialFormLetStar, args)
+	if err != nil {
+		return nil, err
+	}
+
+	newEnv := NewEnvironment(env)
+	var dyn dynamicBindings
+	defer dyn.restore()
+	for _, b := range bindings {
+		value, err := evalPrimary(b.init, newEnv)
+		if err != nil {
+			return nil, err
+		}
+		if err := newEnv.bind(b.name, value, &dyn); err != nil {
+			return nil, fmt.Errorf("%s: %w", SpecialFormLetStar, err)
+		}
+	}
+	return evalBody(body, newEnv)
+}
+
+// (flet ((name lambda-list body*)*) declaration* body*)
+// 局所的な関数を定義する
+// 関数は変数とは別の名前空間に束縛する
+// 関数本体は外側の環境で閉じるので、関数どうしや自分自身は呼べない
+func evalFlet(args types.Expr, env *Environment) (types.Expr, error) {
+	return evalLocalFunctions(SpecialFormFlet, args, env, false)
+}
+
+// (labels ((name lambda-list body*)*) declaration* body*)
+// fletと違い、関数本体は新しい環境で閉じるので、再帰や相互再帰ができる
+func evalLabels(args types.Expr, env *Environment) (types.Expr, error) {
+	return evalLocalFunctions(SpecialFormLabels, args, env, true)
+}
+
+func evalLocalFunctions(name string, args types.Expr, env *Environment, recursive bool) (types.Expr, error) {
+	cons, ok := args.(*types.Cons)
+	if !ok {
+		return nil, fmt.Errorf("%s requires a function list", name)
+	}
+	definitions, err := listToSlice(cons.Car)
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid function list", name)
+	}
+	forms, err := listToSlice(cons.Cdr)
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid body", name)
+	}
+
+	newEnv := NewEnvironment(env)
+	closure := env
+	if recursive {
+		closure = newEnv
+	}
+	for _, def := range definitions {
+		// (name lambda-list body*)
+		defCons, ok := def.(*types.Cons)
+		if !ok {
+			return nil, fmt.Errorf("%s: invalid function definition: %v", name, def)
+		}
+		fnName, ok := defCons.Car.(*types.Symbol)
+		if !ok {
+			return nil, fmt.Errorf("%s: function name must be a symbol, got %v", name, defCons.Car)
+		}
+		lambda, err := evalLambda(defCons.Cdr, closure)
+		if err != nil {
+			return nil, err
+		}
+		newEnv.SetFunction(fnName, lambda)
+	}
+
+	_, body := parseBody(forms, false)
+	return evalBody(body, newEnv)
+}
+
+// letの変数と初期値の式
+type letBinding struct {
+	name *types.Symbol
+	init types.Expr
+}
+
+// (let (binding*) declaration* body*)の束縛と本体を取り出す
+func parseLetForm(name string, args types.Expr) ([]letBinding, []types.Expr, error) {
+	cons, ok := args.(*types.Cons)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s requires a binding list", name)
+	}
+	items, err := listToSlice(cons.Car)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: invalid binding list", name)
+	}
+	forms, err := listToSlice(cons.Cdr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: invalid body", name)
+	}
+
+	bindings := make([]letBinding, len(items))
+	for i, item := range items {
+		if bindings[i], err = parseLetBinding(name, item); err != nil {
+			return nil, nil, err
+		}
+	}
+	_, body := parseBody(forms, false)
+	return bindings, body, nil
+}
+
+// var か (var) か (var init)
+func parseLetBinding(name string, expr types.Expr) (letBinding, error) {
+	items := []types.Expr{expr}
+	if _, ok := expr.(*types.Symbol); !ok {
+		var err error
+		items, err = listToSlice(expr)
+		if err != nil || len(items) == 0 || len(items) > 2 {
+			return letBinding{}, fmt.Errorf("%s: invalid binding: %v", name, expr)
+		}
+	}
+	sym, ok := items[0].(*types.Symbol)
+	if !ok || sym.IsKeyword() {
+		return letBinding{}, fmt.Errorf("%s: variable must be a non-keyword symbol, got %v", name, items[0])
+	}
+	b := letBinding{name: sym, init: &types.Nil{}}
+	if len(items) == 2 {
+		b.init = items[1]
+	}
+	return b, nil
+}
@@ -0,0 +1,110 @@
+// リストを扱う組み込み関数
+package eval
+
+import (
+	"fmt"
+
+	"github.com/koplec/gospl/internal/types"
+)
+
+func registerListBuiltins(env *Environment) {
+	env.define("cons", &BuiltinFunc{Name: "cons", Fn: builtinCons})
+	env.define("car", &BuiltinFunc{Name: "car", Fn: builtinCar})
+	env.define("cdr", &BuiltinFunc{Name: "cdr", Fn: builtinCdr})
+	env.define("list", &BuiltinFunc{Name: "list", Fn: builtinList})
+	env.define("nth", &BuiltinFunc{Name: "nth", Fn: builtinNth})
+}
+
+// (cons x y)
+func builtinCons(args []types.Expr) (types.Expr, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("cons requires exactly 2 arguments")
+	}
+	return &types.Cons{Car: args[0], Cdr: args[1]}, nil
+}
+
+// (car list)
+// (car nil)はNIL
+func builtinCar(args []types.Expr) (types.Expr, error) {
+	list, err := listArg("car", args)
+	if err != nil {
+		return nil, err
+	}
+	if list == nil {
+		return &types.Nil{}, nil
+	}
+	return list.Car, nil
+}
+
+// (cdr list)
+// (cdr nil)はNIL
+func builtinCdr(args []types.Expr) (types.Expr, error) {
+	list, err := listArg("cdr", args)
+	if err != nil {
+		return nil, err
+	}
+	if list == nil {
+		return &types.Nil{}, nil
+	}
+	return list.Cdr, nil
+}
+
+// (list object*)
+func builtinList(args []types.Expr) (types.Expr, error) {
+	return sliceToList(args), nil
+}
+
+// (nth n list)
+// 0から数えてn番目の要素、リストが短ければNIL
+func builtinNth(args []types.Expr) (types.Expr, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("nth requires exactly 2 arguments")
+	}
+	cell, err := nthCell("nth", args[0], args[1])
+	if err != nil {
+		return nil, err
+	}
+	if cell == nil {
+		return &types.Nil{}, nil
+	}
+	return cell.Car, nil
+}
+
+// listのn番目の要素を持つコンス
+// リストが短ければnil
+func nthCell(fn string, n, list types.Expr) (*types.Cons, error) {
+	index, ok := n.(types.Fixnum)
+	if !ok || index.Value < 0 {
+		return nil, fmt.Errorf("%s: not a non-negative integer: %v", fn, n)
+	}
+	current := list
+	for i := int64(0); ; i++ {
+		if _, ok := current.(*types.Nil); ok {
+			return nil, nil
+		}
+		cons, ok := current.(*types.Cons)
+		if !ok {
+			return nil, fmt.Errorf("%s: not a list: %v", fn, list)
+		}
+		if i == index.Value {
+			return cons, nil
+		}
+		current = cons.Cdr
+	}
+}
+
+// 引数がリスト1つであることを確認して返す
+// 空リストならnil
+func listArg(fn string, args []types.Expr) (*types.Cons, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("%s requires exactly 1 argument", fn)
+	}
+	switch list := args[0].(type) {
+	case *types.Cons:
+		return list, nil
+	case *types.Nil:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("%s: not a list: %v", fn, args[0])
+	}
+}
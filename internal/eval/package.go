@@ -0,0 +1,251 @@
+// パッケージとシンボルを操作する組み込み関数
+package eval
+
+import (
+	"fmt"
+
+	"github.com/koplec/gospl/internal/types"
+)
+
+// パッケージを探す関数は、環境のパッケージの表を使うので、環境を覚えておくクロージャにする
+func registerPackageBuiltins(env *Environment) {
+	env.define("find-package", &BuiltinFunc{
+		Name: "find-package",
+		Fn: func(args []types.Expr) (types.Expr, error) {
+			return builtinFindPackage(env, args)
+		},
+	})
+	env.define("make-package", &BuiltinFunc{
+		Name: "make-package",
+		Fn: func(args []types.Expr) (types.Expr, error) {
+			return builtinMakePackage(env, args)
+		},
+	})
+	env.define("package-name", &BuiltinFunc{
+		Name: "package-name",
+		Fn: func(args []types.Expr) (types.Expr, error) {
+			return builtinPackageName(env, args)
+		},
+	})
+	env.define("intern", &BuiltinFunc{
+		Name: "intern",
+		Fn: func(args []types.Expr) (types.Expr, error) {
+			return builtinIntern(env, args)
+		},
+	})
+	env.define("export", &BuiltinFunc{
+		Name: "export",
+		Fn: func(args []types.Expr) (types.Expr, error) {
+			return builtinExport(env, args)
+		},
+	})
+	env.define("symbol-name", &BuiltinFunc{Name: "symbol-name", Fn: builtinSymbolName})
+	env.define("symbol-package", &BuiltinFunc{Name: "symbol-package", Fn: builtinSymbolPackage})
+	env.define("keywordp", &BuiltinFunc{Name: "keywordp", Fn: builtinKeywordp})
+}
+
+// (find-package name)
+// 見つからなければNIL
+func builtinFindPackage(env *Environment, args []types.Expr) (types.Expr, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("find-package requires exactly 1 argument")
+	}
+	if pkg, ok := args[0].(*types.Package); ok {
+		return pkg, nil
+	}
+	name, err := stringDesignator("find-package", args[0])
+	if err != nil {
+		return nil, err
+	}
+	if pkg, ok := env.Packages().FindPackage(name); ok {
+		return pkg, nil
+	}
+	return &types.Nil{}, nil
+}
+
+// (make-package name &key nicknames use)
+// (make-package "myapp" :nicknames '("app") :use '("common-lisp"))
+func builtinMakePackage(env *Environment, args []types.Expr) (types.Expr, error) {
+	if len(args) == 0 || len(args)%2 != 1 {
+		return nil, fmt.Errorf("make-package requires a name and keyword arguments")
+	}
+	name, err := stringDesignator("make-package", args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	var nicknames []string
+	var uses []*types.Package
+	for i := 1; i < len(args); i += 2 {
+		key, ok := args[i].(*types.Symbol)
+		if !ok || !key.IsKeyword() {
+			return nil, fmt.Errorf("make-package: not a keyword: %v", args[i])
+		}
+		values, err := listToSlice(args[i+1])
+		if err != nil {
+			return nil, fmt.Errorf("make-package: %s must be a list", key)
+		}
+
+		switch key.Name {
+		case "nicknames":
+			for _, v := range values {
+				nickname, err := stringDesignator("make-package", v)
+				if err != nil {
+					return nil, err
+				}
+				nicknames = append(nicknames, nickname)
+			}
+		case "use":
+			for _, v := range values {
+				pkg, err := packageDesignator(env, "make-package", v)
+				if err != nil {
+					return nil, err
+				}
+				uses = append(uses, pkg)
+			}
+		default:
+			return nil, fmt.Errorf("make-package: unknown keyword %s", key)
+		}
+	}
+
+	pkg, err := env.Packages().MakePackage(name, nicknames...)
+	if err != nil {
+		return nil, fmt.Errorf("make-package: %w", err)
+	}
+	pkg.Use(uses...)
+	return pkg, nil
+}
+
+// (package-name package)
+func builtinPackageName(env *Environment, args []types.Expr) (types.Expr, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("package-name requires exactly 1 argument")
+	}
+	pkg, err := packageDesignator(env, "package-name", args[0])
+	if err != nil {
+		return nil, err
+	}
+	return types.String{Value: pkg.Name}, nil
+}
+
+// (intern name &optional package)
+// packageを省略するとcommon-lisp-user
+func builtinIntern(env *Environment, args []types.Expr) (types.Expr, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return nil, fmt.Errorf("intern requires 1 or 2 arguments")
+	}
+	name, ok := args[0].(types.String)
+	if !ok {
+		return nil, fmt.Errorf("intern: not a string: %v", args[0])
+	}
+	pkg, err := optionalPackage(env, "intern", args[1:])
+	if err != nil {
+		return nil, err
+	}
+	return pkg.Intern(name.Value), nil
+}
+
+// (export symbols &optional package)
+// symbolsはシンボル1つか、シンボルのリスト
+func builtinExport(env *Environment, args []types.Expr) (types.Expr, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return nil, fmt.Errorf("export requires 1 or 2 arguments")
+	}
+	pkg, err := optionalPackage(env, "export", args[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	syms := []types.Expr{args[0]}
+	if _, ok := args[0].(*types.Symbol); !ok {
+		if syms, err = listToSlice(args[0]); err != nil {
+			return nil, fmt.Errorf("export: not a symbol or a list: %v", args[0])
+		}
+	}
+	for _, s := range syms {
+		sym, ok := s.(*types.Symbol)
+		if !ok {
+			return nil, fmt.Errorf("export: not a symbol: %v", s)
+		}
+		if found, status := pkg.FindSymbol(sym.Name); status == types.SymbolNotFound || found != sym {
+			return nil, fmt.Errorf("export: symbol %v is not accessible in %s", sym, pkg.Name)
+		}
+		pkg.Export(sym.Name)
+	}
+	return types.Boolean{Value: true}, nil
+}
+
+// (symbol-name symbol)
+func builtinSymbolName(args []types.Expr) (types.Expr, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("symbol-name requires exactly 1 argument")
+	}
+	sym, ok := args[0].(*types.Symbol)
+	if !ok {
+		return nil, fmt.Errorf("symbol-name: not a symbol: %v", args[0])
+	}
+	return types.String{Value: sym.Name}, nil
+}
+
+// (symbol-package symbol)
+// どこにも属さないシンボルならNIL
+func builtinSymbolPackage(args []types.Expr) (types.Expr, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("symbol-package requires exactly 1 argument")
+	}
+	sym, ok := args[0].(*types.Symbol)
+	if !ok {
+		return nil, fmt.Errorf("symbol-package: not a symbol: %v", args[0])
+	}
+	if sym.Package == nil {
+		return &types.Nil{}, nil
+	}
+	return sym.Package, nil
+}
+
+// (keywordp x)
+func builtinKeywordp(args []types.Expr) (types.Expr, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("keywordp requires exactly 1 argument")
+	}
+	sym, ok := args[0].(*types.Symbol)
+	return types.Boolean{Value: ok && sym.IsKeyword()}, nil
+}
+
+// 文字列かシンボルを名前として受け取る
+// (find-package "cl")も(find-package :cl)も使えるようにする
+func stringDesignator(fn string, expr types.Expr) (string, error) {
+	switch e := expr.(type) {
+	case types.String:
+		return e.Value, nil
+	case *types.Symbol:
+		return e.Name, nil
+	default:
+		return "", fmt.Errorf("%s: not a string or a symbol: %v", fn, expr)
+	}
+}
+
+// パッケージか、パッケージの名前を受け取る
+func packageDesignator(env *Environment, fn string, expr types.Expr) (*types.Package, error) {
+	if pkg, ok := expr.(*types.Package); ok {
+		return pkg, nil
+	}
+	name, err := stringDesignator(fn, expr)
+	if err != nil {
+		return nil, err
+	}
+	pkg, ok := env.Packages().FindPackage(name)
+	if !ok {
+		return nil, fmt.Errorf("%s: package %s does not exist", fn, name)
+	}
+	return pkg, nil
+}
+
+// 省略できるパッケージの引数
+// 省略されたときはcommon-lisp-user
+func optionalPackage(env *Environment, fn string, args []types.Expr) (*types.Package, error) {
+	if len(args) == 0 {
+		return env.Package(), nil
+	}
+	return packageDesignator(env, fn, args[0])
+}
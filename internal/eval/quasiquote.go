@@ -0,0 +1,154 @@
+// 準クォート（バッククォート）
+package eval
+
+import (
+	"fmt"
+
+	"github.com/koplec/gospl/internal/types"
+)
+
+// (quasiquote template)
+// templateの中の,xはxを評価した値に、,@xはxを評価したリストを展開したものに置き換える
+// それ以外はquoteと同じく評価しない
+func evalQuasiquote(args types.Expr, env *Environment) (types.Expr, error) {
+	cons, ok := args.(*types.Cons)
+	if !ok {
+		return nil, fmt.Errorf("quasiquote requires exactly 1 argument")
+	}
+	if _, ok := cons.Cdr.(*types.Nil); !ok {
+		return nil, fmt.Errorf("quasiquote requires exactly 1 argument")
+	}
+
+	return quasiExpand(cons.Car, 1, env)
+}
+
+// templateを展開する
+// depthはバッククォートのネストの深さ
+// `(a `(b ,,x))のように入れ子になっているときは、depthが1のところだけ評価する
+func quasiExpand(template types.Expr, depth int, env *Environment) (types.Expr, error) {
+	cons, ok := template.(*types.Cons)
+	if !ok {
+		// アトムはそのまま
+		return template, nil
+	}
+
+	if name, arg, ok := quasiForm(cons); ok {
+		switch name {
+		case SpecialFormUnquote:
+			if depth == 1 {
+				return evalPrimary(arg, env)
+			}
+			return expandQuasiForm(name, arg, depth-1, env)
+		case SpecialFormUnquoteSplicing:
+			if depth == 1 {
+				// `,@xのようにリストの要素の位置にない
+				return nil, fmt.Errorf(",@ must appear inside a list")
+			}
+			return expandQuasiForm(name, arg, depth-1, env)
+		case SpecialFormQuasiquote:
+			return expandQuasiForm(name, arg, depth+1, env)
+		}
+	}
+
+	return quasiExpandList(cons, depth, env)
+}
+
+// (a b ,@c . d)のようなリストを要素ごとに展開する
+func quasiExpandList(list *types.Cons, depth int, env *Environment) (types.Expr, error) {
+	var head, tail *types.Cons
+	appendElem := func(expr types.Expr) {
+		cons := &types.Cons{Car: expr, Cdr: &types.Nil{}}
+		if head == nil {
+			head = cons
+		} else {
+			tail.Cdr = cons
+		}
+		tail = cons
+	}
+
+	var current types.Expr = list
+	for {
+		cons, ok := current.(*types.Cons)
+		if !ok {
+			break
+		}
+
+		// (a . ,b)は(a unquote b)と同じ構造なので、途中に現れた(unquote b)は末尾として扱う
+		if cons != list {
+			if _, _, ok := quasiForm(cons); ok {
+				break
+			}
+		}
+
+		// ,@xは評価したリストの要素を展開する
+		if elem, ok := cons.Car.(*types.Cons); ok && depth == 1 {
+			if name, arg, ok := quasiForm(elem); ok && name == SpecialFormUnquoteSplicing {
+				spliced, err := evalPrimary(arg, env)
+				if err != nil {
+					return nil, err
+				}
+				items, err := listToSlice(spliced)
+				if err != nil {
+					return nil, fmt.Errorf(",@ expects a list, got %v", spliced)
+				}
+				for _, item := range items {
+					appendElem(item)
+				}
+				current = cons.Cdr
+				continue
+			}
+		}
+
+		elem, err := quasiExpand(cons.Car, depth, env)
+		if err != nil {
+			return nil, err
+		}
+		appendElem(elem)
+		current = cons.Cdr
+	}
+
+	// 末尾（NILか、ドット対のcdr）
+	rest, err := quasiExpand(current, depth, env)
+	if err != nil {
+		return nil, err
+	}
+	if head == nil {
+		// (,@nil)のように要素がなくなった
+		return rest, nil
+	}
+	tail.Cdr = rest
+	return head, nil
+}
+
+// (unquote x)、(unquote-splicing x)、(quasiquote x)の形かどうか
+// その場合は名前と引数xを返す
+func quasiForm(cons *types.Cons) (string, types.Expr, bool) {
+	name, ok := specialFormName(cons.Car)
+	if !ok {
+		return "", nil, false
+	}
+	switch name {
+	case SpecialFormUnquote, SpecialFormUnquoteSplicing, SpecialFormQuasiquote:
+	default:
+		return "", nil, false
+	}
+
+	rest, ok := cons.Cdr.(*types.Cons)
+	if !ok {
+		return "", nil, false
+	}
+	if _, ok := rest.Cdr.(*types.Nil); !ok {
+		return "", nil, false
+	}
+	return name, rest.Car, true
+}
+
+// 入れ子のバッククォートの中の(name arg)を、argだけ展開して作り直す
+// `(a `(b ,,@x))のようにargが,@xのときは、xの要素が(name x1 x2 ...)に展開される
+func expandQuasiForm(name string, arg types.Expr, depth int, env *Environment) (types.Expr, error) {
+	args, err := quasiExpandList(&types.Cons{Car: arg, Cdr: &types.Nil{}}, depth, env)
+	if err != nil {
+		return nil, err
+	}
+	return &types.Cons{Car: env.Packages().CommonLisp.Export(name), Cdr: args}, nil
+}
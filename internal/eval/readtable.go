@@ -0,0 +1,222 @@
+// リードテーブルを操作する組み込み関数
+package eval
+
+import (
+	"fmt"
+
+	"github.com/koplec/gospl/internal/reader"
+	"github.com/koplec/gospl/internal/types"
+)
+
+// 現在のリードテーブルを束縛する変数
+func readtableVar(env *Environment) *types.Symbol {
+	return env.Packages().CommonLisp.Export("*readtable*")
+}
+
+// リードテーブルの組み込み関数を登録する
+// 省略されたときは*readtable*を使うので、環境を覚えておくクロージャにする
+func registerReadtableBuiltins(env *Environment) {
+	// *readtable*はスペシャル変数なので、letで束縛している間はそのリードテーブルを使う
+	sym := readtableVar(env)
+	sym.Special = true
+	env.Set(sym, standardReadtable(env))
+
+	env.define("copy-readtable", &BuiltinFunc{
+		Name: "copy-readtable",
+		Fn: func(args []types.Expr) (types.Expr, error) {
+			return builtinCopyReadtable(env, args)
+		},
+	})
+	env.define("set-macro-character", &BuiltinFunc{
+		Name: "set-macro-character",
+		Fn: func(args []types.Expr) (types.Expr, error) {
+			return builtinSetMacroCharacter(env, args)
+		},
+	})
+	env.define("set-dispatch-macro-character", &BuiltinFunc{
+		Name: "set-dispatch-macro-character",
+		Fn: func(args []types.Expr) (types.Expr, error) {
+			return builtinSetDispatchMacroCharacter(env, args)
+		},
+	})
+}
+
+// 現在のリードテーブル（*readtable*の値）
+// REPLやファイルの読み込みで、次の式を読むParserに渡す
+func (e *Environment) Readtable() *reader.Readtable {
+	if value, err := e.Get(readtableVar(e)); err == nil {
+		if rt, ok := value.(*reader.Readtable); ok {
+			return rt
+		}
+	}
+	return standardReadtable(e)
+}
+
+// 標準のリードテーブル
+// readerの標準のリードテーブルに、構造体を読む#Sを加える
+// #Sは構造体のコンストラクタを呼ぶので、環境を覚えておくクロージャにする
+func standardReadtable(env *Environment) *reader.Readtable {
+	rt := reader.NewReadtable()
+	err := rt.SetDispatchMacroCharacter('#', "s", func(p *reader.Parser, text string) (types.Expr, error) {
+		return readStructure(p, env)
+	})
+	if err != nil {
+		panic(err)
+	}
+	return rt
+}
+
+// (copy-readtable &optional from to)
+// fromを省略すると現在のリードテーブル、nilなら標準のリードテーブルをコピーする
+// toを渡すと、toの中身をfromのコピーで置き換える
+func builtinCopyReadtable(env *Environment, args []types.Expr) (types.Expr, error) {
+	if len(args) > 2 {
+		return nil, fmt.Errorf("copy-readtable takes at most 2 arguments")
+	}
+
+	from := env.Readtable()
+	if len(args) >= 1 {
+		if _, ok := args[0].(*types.Nil); ok {
+			from = standardReadtable(env)
+		} else {
+			rt, ok := args[0].(*reader.Readtable)
+			if !ok {
+				return nil, fmt.Errorf("copy-readtable: not a readtable: %v", args[0])
+			}
+			from = rt
+		}
+	}
+
+	if len(args) == 2 {
+		if _, ok := args[1].(*types.Nil); !ok {
+			to, ok := args[1].(*reader.Readtable)
+			if !ok {
+				return nil, fmt.Errorf("copy-readtable: not a readtable: %v", args[1])
+			}
+			*to = *from.Copy()
+			return to, nil
+		}
+	}
+
+	return from.Copy(), nil
+}
+
+// (set-macro-character char function &optional non-terminating-p readtable)
+// functionは入力ストリームとマクロ文字を受け取り、読んだ結果になる式を返す
+// (set-macro-character #\! (lambda (stream char) (list 'not (read stream))))
+// charは#\!のような文字か、1文字の文字列で渡す
+func builtinSetMacroCharacter(env *Environment, args []types.Expr) (types.Expr, error) {
+	if len(args) < 2 || len(args) > 4 {
+		return nil, fmt.Errorf("set-macro-character requires 2 to 4 arguments")
+	}
+
+	ch, err := macroCharArg("set-macro-character", args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	nonTerminating := false
+	if len(args) >= 3 {
+		nonTerminating = isTrue(args[2])
+	}
+
+	rt, err := readtableArg(env, "set-macro-character", args, 3)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := rt.SetMacroCharacter(ch, lispReaderMacro(args[1], false), nonTerminating); err != nil {
+		return nil, err
+	}
+	return types.Boolean{Value: true}, nil
+}
+
+// (set-dispatch-macro-character disp-char sub-char function &optional readtable)
+// sub-charは文字か、"date"のように複数文字の文字列
+// functionは入力ストリームとサブ文字と数値の引数を受け取る
+// 数値の引数は#と文字の間に書く数だが、gosplでは書けないので常にNIL
+func builtinSetDispatchMacroCharacter(env *Environment, args []types.Expr) (types.Expr, error) {
+	if len(args) < 3 || len(args) > 4 {
+		return nil, fmt.Errorf("set-dispatch-macro-character requires 3 or 4 arguments")
+	}
+
+	disp, err := macroCharArg("set-dispatch-macro-character", args[0])
+	if err != nil {
+		return nil, err
+	}
+	var sub string
+	switch s := args[1].(type) {
+	case types.Character:
+		sub = string(s.Value)
+	case types.String:
+		sub = s.Value
+	default:
+		return nil, fmt.Errorf("set-dispatch-macro-character: sub-character must be a character or a string, got %v", args[1])
+	}
+
+	rt, err := readtableArg(env, "set-dispatch-macro-character", args, 3)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := rt.SetDispatchMacroCharacter(disp, sub, lispReaderMacro(args[2], true)); err != nil {
+		return nil, err
+	}
+	return types.Boolean{Value: true}, nil
+}
+
+// Lispの関数をリーダマクロの関数にする
+// マクロ文字なら(function stream char)、ディスパッチマクロなら(function stream sub-char nil)で呼ぶ
+// 関数はstreamからread-char、peek-char、readで続きを読むので、Goのリーダマクロと同じことができる
+// サブ文字が複数文字のときは文字列で渡す
+func lispReaderMacro(fn types.Expr, dispatch bool) reader.ReaderMacro {
+	return func(p *reader.Parser, text string) (types.Expr, error) {
+		stream := &inputStream{parser: p}
+		defer stream.close()
+
+		runes := []rune(text)
+		args := []types.Expr{stream, types.Character{Value: runes[0]}}
+		if dispatch {
+			// textは#dateのような形
+			var sub types.Expr = types.String{Value: string(runes[1:])}
+			if len(runes) == 2 {
+				sub = types.Character{Value: runes[1]}
+			}
+			args = []types.Expr{stream, sub, &types.Nil{}}
+		}
+
+		result, err := apply(fn, args)
+		if err != nil {
+			return nil, err
+		}
+		return types.PrimaryValue(result), nil
+	}
+}
+
+// 文字か1文字の文字列をruneにする
+func macroCharArg(name string, arg types.Expr) (rune, error) {
+	if ch, ok := arg.(types.Character); ok {
+		return ch.Value, nil
+	}
+	str, ok := arg.(types.String)
+	if !ok {
+		return 0, fmt.Errorf("%s: character must be a character or a string, got %v", name, arg)
+	}
+	runes := []rune(str.Value)
+	if len(runes) != 1 {
+		return 0, fmt.Errorf("%s: character must be exactly 1 character, got %v", name, arg)
+	}
+	return runes[0], nil
+}
+
+// args[i]にリードテーブルがあればそれを、なければ現在のリードテーブルを返す
+func readtableArg(env *Environment, name string, args []types.Expr, i int) (*reader.Readtable, error) {
+	if len(args) <= i {
+		return env.Readtable(), nil
+	}
+	rt, ok := args[i].(*reader.Readtable)
+	if !ok {
+		return nil, fmt.Errorf("%s: not a readtable: %v", name, args[i])
+	}
+	return rt, nil
+}
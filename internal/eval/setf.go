@@ -0,0 +1,423 @@
+// 代入と、setfで書き換えられる場所
+package eval
+
+import (
+	"fmt"
+
+	"github.com/koplec/gospl/internal/types"
+)
+
+func registerSetfBuiltins(env *Environment) {
+	defineSetf(env, "car", builtinSetfCar)
+	defineSetf(env, "cdr", builtinSetfCdr)
+	defineSetf(env, "nth", builtinSetfNth)
+	defineSetf(env, "gethash", builtinSetfGethash)
+	defineSetf(env, "aref", builtinSetfAref)
+	defineSetf(env, "symbol-value", builtinSetfSymbolValue)
+	defineSetf(env, "symbol-function", builtinSetfSymbolFunction)
+	defineSetf(env, "get", builtinSetfGet)
+}
+
+// 組み込みのアクセサnameのsetfの関数を登録する
+func defineSetf(env *Environment, name string, fn BuiltinFn) {
+	env.setfFunctions()[env.Packages().CommonLisp.Export(name)] = &BuiltinFunc{Name: "(setf " + name + ")", Fn: fn}
+}
+
+// (setf (car cons) value)
+func builtinSetfCar(args []types.Expr) (types.Expr, error) {
+	cons, err := setfConsArg("car", args)
+	if err != nil {
+		return nil, err
+	}
+	cons.Car = args[0]
+	return args[0], nil
+}
+
+// (setf (cdr cons) value)
+func builtinSetfCdr(args []types.Expr) (types.Expr, error) {
+	cons, err := setfConsArg("cdr", args)
+	if err != nil {
+		return nil, err
+	}
+	cons.Cdr = args[0]
+	return args[0], nil
+}
+
+// (setf (nth n list) value)
+// リストより後ろの位置はエラー
+func builtinSetfNth(args []types.Expr) (types.Expr, error) {
+	if len(args) != 3 {
+		return nil, fmt.Errorf("(setf nth) requires exactly 3 arguments")
+	}
+	cell, err := nthCell("(setf nth)", args[1], args[2])
+	if err != nil {
+		return nil, err
+	}
+	if cell == nil {
+		return nil, fmt.Errorf("(setf nth): index %v is out of range for %v", args[1], args[2])
+	}
+	cell.Car = args[0]
+	return args[0], nil
+}
+
+// (setf (gethash key table &optional default) value)
+// defaultは使わない
+func builtinSetfGethash(args []types.Expr) (types.Expr, error) {
+	if len(args) < 3 || len(args) > 4 {
+		return nil, fmt.Errorf("(setf gethash) requires 3 or 4 arguments")
+	}
+	table, err := hashTableArg("(setf gethash)", args[2:3])
+	if err != nil {
+		return nil, err
+	}
+	table.Put(args[1], args[0])
+	return args[0], nil
+}
+
+// (setf (aref array subscript*) value)
+func builtinSetfAref(args []types.Expr) (types.Expr, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("(setf aref) requires at least 2 arguments")
+	}
+	array, index, err := arrayIndex("(setf aref)", args[1], args[2:])
+	if err != nil {
+		return nil, err
+	}
+	array.Elements[index] = args[0]
+	return args[0], nil
+}
+
+// (setf (symbol-value symbol) value)
+// グローバルな値（値セル）を書き換える
+func builtinSetfSymbolValue(args []types.Expr) (types.Expr, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("(setf symbol-value) requires exactly 2 arguments")
+	}
+	sym, err := symbolArg("(setf symbol-value)", args[1:])
+	if err != nil {
+		return nil, err
+	}
+	if sym.Constant {
+		return nil, fmt.Errorf("(setf symbol-value): cannot assign to constant %s", sym)
+	}
+	sym.Value = args[0]
+	return args[0], nil
+}
+
+// (setf (symbol-function symbol) function)
+// グローバルな関数（関数セル）を書き換える
+func builtinSetfSymbolFunction(args []types.Expr) (types.Expr, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("(setf symbol-function) requires exactly 2 arguments")
+	}
+	sym, err := symbolArg("(setf symbol-function)", args[1:])
+	if err != nil {
+		return nil, err
+	}
+	switch args[0].(type) {
+	case *BuiltinFunc, *Lambda:
+	default:
+		return nil, fmt.Errorf("(setf symbol-function): not a function: %v", args[0])
+	}
+	sym.Function = args[0]
+	return args[0], nil
+}
+
+// (setf (get symbol indicator &optional default) value)
+// defaultは使わない
+func builtinSetfGet(args []types.Expr) (types.Expr, error) {
+	if len(args) < 3 || len(args) > 4 {
+		return nil, fmt.Errorf("(setf get) requires 3 or 4 arguments")
+	}
+	sym, err := symbolArg("(setf get)", args[1:2])
+	if err != nil {
+		return nil, err
+	}
+	sym.Put(args[2], args[0])
+	return args[0], nil
+}
+
+// (setf (car cons) value)などの引数が、値とコンスであることを確認してコンスを返す
+func setfConsArg(fn string, args []types.Expr) (*types.Cons, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("(setf %s) requires exactly 2 arguments", fn)
+	}
+	cons, ok := args[1].(*types.Cons)
+	if !ok {
+		return nil, fmt.Errorf("(setf %s): not a cons: %v", fn, args[1])
+	}
+	return cons, nil
+}
+
+// setfで書き換えられる場所
+// (aref v (f))のような場所の引数は一度だけ評価して、getとsetで同じ値を使う
+// そのため(incf (aref v (f)))でもfは一度しか呼ばれない
+type place struct {
+	get func() (types.Expr, error)
+	set func(value types.Expr) (types.Expr, error)
+}
+
+// 場所の式を評価する
+// シンボルなら変数、(accessor args...)ならaccessorのsetfの関数で書き換える
+func evalPlace(fn string, expr types.Expr, env *Environment) (*place, error) {
+	switch p := expr.(type) {
+	case *types.Symbol:
+		if p.IsKeyword() {
+			return nil, fmt.Errorf("%s: cannot assign to keyword %s", fn, p)
+		}
+		return &place{
+			get: func() (types.Expr, error) {
+				return env.Get(p)
+			},
+			set: func(value types.Expr) (types.Expr, error) {
+				if err := env.Assign(p, value); err != nil {
+					return nil, fmt.Errorf("%s: %w", fn, err)
+				}
+				return value, nil
+			},
+		}, nil
+
+	case *types.Cons:
+		accessor, ok := p.Car.(*types.Symbol)
+		if !ok {
+			return nil, fmt.Errorf("%s: invalid place: %v", fn, expr)
+		}
+		setter, ok := env.setfFunctions()[accessor]
+		if !ok {
+			return nil, fmt.Errorf("%s: no setf function for %s", fn, accessor)
+		}
+		args, err := evalArgs(p.Cdr, env)
+		if err != nil {
+			return nil, err
+		}
+		return &place{
+			get: func() (types.Expr, error) {
+				getter, err := env.GetFunction(accessor)
+				if err != nil {
+					return nil, err
+				}
+				result, err := apply(getter, args)
+				if err != nil {
+					return nil, err
+				}
+				return types.PrimaryValue(result), nil
+			},
+			set: func(value types.Expr) (types.Expr, error) {
+				result, err := apply(setter, append([]types.Expr{value}, args...))
+				if err != nil {
+					return nil, err
+				}
+				return types.PrimaryValue(result), nil
+			},
+		}, nil
+	}
+	return nil, fmt.Errorf("%s: invalid place: %v", fn, expr)
+}
+
+// (setq {var form}*)
+// 変数が束縛されている一番近い環境で値を書き換える
+// 最後の値を返す
+func evalSetq(args types.Expr, env *Environment) (types.Expr, error) {
+	items, err := listToSlice(args)
+	if err != nil || len(items)%2 != 0 {
+		return nil, fmt.Errorf("setq requires an even number of arguments")
+	}
+
+	var result types.Expr = &types.Nil{}
+	for i := 0; i < len(items); i += 2 {
+		sym, ok := items[i].(*types.Symbol)
+		if !ok || sym.IsKeyword() {
+			return nil, fmt.Errorf("setq: variable must be a non-keyword symbol, got %v", items[i])
+		}
+		if result, err = evalPrimary(items[i+1], env); err != nil {
+			return nil, err
+		}
+		if err := env.Assign(sym, result); err != nil {
+			return nil, fmt.Errorf("setq: %w", err)
+		}
+	}
+	return result, nil
+}
+
+// (setf {place form}*)
+// 場所の値を書き換えて、最後の値を返す
+// 場所がシンボルならsetqと同じ
+func evalSetf(args types.Expr, env *Environment) (types.Expr, error) {
+	items, err := listToSlice(args)
+	if err != nil || len(items)%2 != 0 {
+		return nil, fmt.Errorf("setf requires an even number of arguments")
+	}
+
+	var result types.Expr = &types.Nil{}
+	for i := 0; i < len(items); i += 2 {
+		p, err := evalPlace("setf", items[i], env)
+		if err != nil {
+			return nil, err
+		}
+		value, err := evalPrimary(items[i+1], env)
+		if err != nil {
+			return nil, err
+		}
+		if result, err = p.set(value); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// (defsetf access-fn update-fn)
+// (defsetf access-fn lambda-list (store-var) [documentation] body*)
+//
+// 短い形では、(setf (access-fn args...) value)は(update-fn args... value)になる
+// 長い形では、lambda-listとstore-varを引数と値に束縛してbodyを評価し、その結果の式を評価する
+// 引数と値は評価済みなので、(quote 値)の形で束縛する
+// (defsetf middle (x) (new) `(set-middle ,x ,new))
+func evalDefsetf(args types.Expr, env *Environment) (types.Expr, error) {
+	items, err := listToSlice(args)
+	if err != nil || len(items) < 2 {
+		return nil, fmt.Errorf("defsetf requires an access function and an update function")
+	}
+	accessor, ok := items[0].(*types.Symbol)
+	if !ok {
+		return nil, fmt.Errorf("defsetf: access function name must be a symbol, got %v", items[0])
+	}
+	name := "(setf " + accessor.Name + ")"
+
+	// 短い形
+	if update, ok := items[1].(*types.Symbol); ok && len(items) == 2 {
+		env.setfFunctions()[accessor] = &BuiltinFunc{Name: name, Fn: func(args []types.Expr) (types.Expr, error) {
+			fn, err := env.GetFunction(update)
+			if err != nil {
+				return nil, err
+			}
+			updateArgs := append(append([]types.Expr{}, args[1:]...), args[0])
+			return apply(fn, updateArgs)
+		}}
+		return accessor, nil
+	}
+
+	// 長い形
+	if len(items) < 3 {
+		return nil, fmt.Errorf("defsetf %s: requires a store variable list", accessor)
+	}
+	params, err := parseParams(items[1])
+	if err != nil {
+		return nil, fmt.Errorf("defsetf %s: %v", accessor, err)
+	}
+	stores, err := parseParams(items[2])
+	if err != nil || len(stores) != 1 {
+		return nil, fmt.Errorf("defsetf %s: requires exactly 1 store variable", accessor)
+	}
+	_, body := parseBody(items[3:], true)
+
+	env.setfFunctions()[accessor] = &BuiltinFunc{Name: name, Fn: func(args []types.Expr) (types.Expr, error) {
+		if len(args)-1 != len(params) {
+			return nil, fmt.Errorf("%s: wrong number of arguments: expected %d, got %d", name, len(params), len(args)-1)
+		}
+		newEnv := NewEnvironment(env)
+		for i, param := range params {
+			newEnv.Set(param, quoteForm(args[i+1], env))
+		}
+		newEnv.Set(stores[0], quoteForm(args[0], env))
+
+		expansion, err := evalBody(body, newEnv)
+		if err != nil {
+			return nil, err
+		}
+		return Eval(types.PrimaryValue(expansion), env)
+	}}
+	return accessor, nil
+}
+
+// (quote expr)
+func quoteForm(expr types.Expr, env *Environment) types.Expr {
+	return sliceToList([]types.Expr{env.Packages().CommonLisp.Export(SpecialFormQuote), expr})
+}
+
+// (incf place [delta])
+// (decf place [delta])
+// deltaを省略すると1
+func evalIncf(name string, args types.Expr, env *Environment) (types.Expr, error) {
+	items, err := listToSlice(args)
+	if err != nil || len(items) < 1 || len(items) > 2 {
+		return nil, fmt.Errorf("%s requires 1 or 2 arguments", name)
+	}
+	p, err := evalPlace(name, items[0], env)
+	if err != nil {
+		return nil, err
+	}
+	old, err := p.get()
+	if err != nil {
+		return nil, err
+	}
+
+	var delta types.Expr = types.Fixnum{Value: 1}
+	if len(items) == 2 {
+		if delta, err = evalPrimary(items[1], env); err != nil {
+			return nil, err
+		}
+	}
+	a, err := numberArg(name, old)
+	if err != nil {
+		return nil, err
+	}
+	b, err := numberArg(name, delta)
+	if err != nil {
+		return nil, err
+	}
+	if name == SpecialFormDecf {
+		return p.set(types.Sub(a, b))
+	}
+	return p.set(types.Add(a, b))
+}
+
+// (push item place)
+// placeのリストの先頭にitemを足して、新しいリストを返す
+func evalPush(args types.Expr, env *Environment) (types.Expr, error) {
+	items, err := listToSlice(args)
+	if err != nil || len(items) != 2 {
+		return nil, fmt.Errorf("push requires exactly 2 arguments")
+	}
+	item, err := evalPrimary(items[0], env)
+	if err != nil {
+		return nil, err
+	}
+	p, err := evalPlace(SpecialFormPush, items[1], env)
+	if err != nil {
+		return nil, err
+	}
+	list, err := p.get()
+	if err != nil {
+		return nil, err
+	}
+	return p.set(&types.Cons{Car: item, Cdr: list})
+}
+
+// (pop place)
+// placeのリストの先頭を取り除いて、取り除いた要素を返す
+// 空リストならNIL
+func evalPop(args types.Expr, env *Environment) (types.Expr, error) {
+	items, err := listToSlice(args)
+	if err != nil || len(items) != 1 {
+		return nil, fmt.Errorf("pop requires exactly 1 argument")
+	}
+	p, err := evalPlace(SpecialFormPop, items[0], env)
+	if err != nil {
+		return nil, err
+	}
+	value, err := p.get()
+	if err != nil {
+		return nil, err
+	}
+	list, err := listArg(SpecialFormPop, []types.Expr{value})
+	if err != nil {
+		return nil, err
+	}
+	if list == nil {
+		return &types.Nil{}, nil
+	}
+	if _, err := p.set(list.Cdr); err != nil {
+		return nil, err
+	}
+	return list.Car, nil
+}
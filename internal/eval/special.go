@@ -3,6 +3,7 @@ package eval
 
 import (
 	"fmt"
+	"slices"
 
 	"github.com/koplec/gospl/internal/types"
 )
@@ -12,17 +13,74 @@ const (
 	SpecialFormIf     = "if"
 	SpecialFormLambda = "lambda"
 	SpecialFormDefun  = "defun"
+
+	SpecialFormFunction = "function"
+
+	SpecialFormProgn = "progn"
+	// declareは関数本体の先頭にだけ書ける
+	// それ以外の場所で評価するとエラーになるように、特殊形式の名前にしておく
+	SpecialFormDeclare = "declare"
+
+	SpecialFormLet     = "let"
+	SpecialFormLetStar = "let*"
+	SpecialFormFlet    = "flet"
+	SpecialFormLabels  = "labels"
+
+	SpecialFormSetq    = "setq"
+	SpecialFormSetf    = "setf"
+	SpecialFormDefsetf = "defsetf"
+	SpecialFormIncf    = "incf"
+	SpecialFormDecf    = "decf"
+	SpecialFormPush    = "push"
+	SpecialFormPop     = "pop"
+
+	SpecialFormDefvar       = "defvar"
+	SpecialFormDefparameter = "defparameter"
+	SpecialFormDefconstant  = "defconstant"
+
+	SpecialFormDefstruct = "defstruct"
+
+	SpecialFormMultipleValueBind = "multiple-value-bind"
+	SpecialFormMultipleValueList = "multiple-value-list"
+	SpecialFormMultipleValueCall = "multiple-value-call"
+	SpecialFormNthValue          = "nth-value"
+
+	SpecialFormQuasiquote      = "quasiquote"
+	SpecialFormUnquote         = "unquote"
+	SpecialFormUnquoteSplicing = "unquote-splicing"
 )
 
-func isSpecialForm(name string) bool {
-	switch name {
-	case SpecialFormDefun, SpecialFormIf, SpecialFormLambda, SpecialFormQuote:
-		return true
-	default:
-		return false
+// 特殊形式の名前の一覧
+// common-lispパッケージの外部シンボルにもなる
+var specialForms = []string{
+	SpecialFormQuote, SpecialFormIf, SpecialFormLambda, SpecialFormDefun,
+	SpecialFormFunction, SpecialFormProgn, SpecialFormDeclare,
+	SpecialFormLet, SpecialFormLetStar, SpecialFormFlet, SpecialFormLabels,
+	SpecialFormSetq, SpecialFormSetf, SpecialFormDefsetf,
+	SpecialFormIncf, SpecialFormDecf, SpecialFormPush, SpecialFormPop,
+	SpecialFormDefvar, SpecialFormDefparameter, SpecialFormDefconstant,
+	SpecialFormDefstruct,
+	SpecialFormMultipleValueBind, SpecialFormMultipleValueList, SpecialFormMultipleValueCall, SpecialFormNthValue,
+	SpecialFormQuasiquote, SpecialFormUnquote, SpecialFormUnquoteSplicing,
+}
+
+// 特殊形式の名前を、common-lispパッケージの外部シンボルとして登録する
+func registerSpecialForms(env *Environment) {
+	for _, name := range specialForms {
+		env.Packages().CommonLisp.Export(name)
 	}
 }
 
+// exprが特殊形式の名前のシンボルなら、その名前を返す
+// 特殊形式の名前はcommon-lispパッケージのシンボルなので、別のパッケージの同じ名前のシンボルは特殊形式ではない
+func specialFormName(expr types.Expr) (string, bool) {
+	sym, ok := expr.(*types.Symbol)
+	if !ok || !isCommonLisp(sym) || !slices.Contains(specialForms, sym.Name) {
+		return "", false
+	}
+	return sym.Name, true
+}
+
 // 特殊形式の評価
 func evalSpecialForm(name string, args types.Expr, env *Environment) (types.Expr, error) {
 	switch name {
@@ -30,10 +88,57 @@ func evalSpecialForm(name string, args types.Expr, env *Environment) (types.Expr
 		return evalQuote(args)
 	case SpecialFormDefun:
 		return evalDefun(args, env)
+	case SpecialFormFunction:
+		return evalFunction(args, env)
+	case SpecialFormProgn:
+		return evalProgn(args, env)
+	case SpecialFormDeclare:
+		return nil, fmt.Errorf("declare is only allowed at the beginning of a body")
+	case SpecialFormLet:
+		return evalLet(args, env)
+	case SpecialFormLetStar:
+		return evalLetStar(args, env)
+	case SpecialFormFlet:
+		return evalFlet(args, env)
+	case SpecialFormLabels:
+		return evalLabels(args, env)
+	case SpecialFormSetq:
+		return evalSetq(args, env)
+	case SpecialFormSetf:
+		return evalSetf(args, env)
+	case SpecialFormDefsetf:
+		return evalDefsetf(args, env)
+	case SpecialFormIncf, SpecialFormDecf:
+		return evalIncf(name, args, env)
+	case SpecialFormPush:
+		return evalPush(args, env)
+	case SpecialFormPop:
+		return evalPop(args, env)
+	case SpecialFormDefvar:
+		return evalDefvar(args, env)
+	case SpecialFormDefparameter:
+		return evalDefparameter(args, env)
+	case SpecialFormDefconstant:
+		return evalDefconstant(args, env)
+	case SpecialFormDefstruct:
+		return evalDefstruct(args, env)
+	case SpecialFormMultipleValueBind:
+		return evalMultipleValueBind(args, env)
+	case SpecialFormMultipleValueList:
+		return evalMultipleValueList(args, env)
+	case SpecialFormMultipleValueCall:
+		return evalMultipleValueCall(args, env)
+	case SpecialFormNthValue:
+		return evalNthValue(args, env)
 	case SpecialFormLambda:
 		return evalLambda(args, env)
 	case SpecialFormIf:
 		return evalIf(args, env)
+	case SpecialFormQuasiquote:
+		return evalQuasiquote(args, env)
+	case SpecialFormUnquote, SpecialFormUnquoteSplicing:
+		// ,や,@は`の中でしか使えない
+		return nil, fmt.Errorf("%s: comma is not inside a backquote", name)
 	default:
 		return nil, fmt.Errorf("unknown special form:%s", name)
 	}
@@ -104,21 +209,13 @@ func evalIf(args types.Expr, env *Environment) (types.Expr, error) {
 	}
 
 	// 条件式の評価
-	condResult, err := Eval(condition, env)
+	condResult, err := evalPrimary(condition, env)
 	if err != nil {
 		return nil, err
 	}
 
 	//条件式の真偽判定
-	// NILとBoolean{Value:false}に注意
-	isFalse := false
-	if _, ok := condResult.(*types.Nil); ok {
-		isFalse = true
-	} else if b, ok := condResult.(types.Boolean); ok && !b.Value {
-		isFalse = true
-	}
-
-	if !isFalse { //すなわち if true
+	if isTrue(condResult) {
 		return Eval(thenExpr, env)
 	} else {
 		return Eval(elseExpr, env)
@@ -126,15 +223,17 @@ func evalIf(args types.Expr, env *Environment) (types.Expr, error) {
 }
 
 func evalDefun(args types.Expr, env *Environment) (types.Expr, error) {
-	// (defun name (params...) body)
+	// (defun name (params...) [documentation] declaration* body*)
 	cons, ok := args.(*types.Cons)
 	if !ok {
 		return nil, fmt.Errorf("defun requires at least 3 arguments")
 	}
 
 	// 関数名
-	name, ok := cons.Car.(types.Symbol)
-	if !ok {
+	// (setf foo)なら、(setf (foo ...) value)で呼ばれる関数を定義する
+	accessor, isSetf := setfFunctionName(cons.Car)
+	name, ok := cons.Car.(*types.Symbol)
+	if !ok && !isSetf {
 		return nil, fmt.Errorf("function name must be a symbol, go %T", cons.Car)
 	}
 
@@ -149,15 +248,56 @@ func evalDefun(args types.Expr, env *Environment) (types.Expr, error) {
 		return nil, err
 	}
 
-	//環境に登録
-	env.Set(name.Name, lambda)
+	if isSetf {
+		env.setfFunctions()[accessor] = lambda
+		return cons.Car, nil
+	}
+
+	//関数セルに登録
+	//defunはletの中でもグローバルな関数を定義する
+	name.Function = lambda
 
 	//シンボルを返す
 	return name, nil
 }
 
+// (function name)
+// #'nameと書いても同じ
+// nameは関数の名前のシンボル、(lambda ...)、(setf foo)のどれか
+func evalFunction(args types.Expr, env *Environment) (types.Expr, error) {
+	cons, ok := args.(*types.Cons)
+	if !ok {
+		return nil, fmt.Errorf("function requires exactly 1 argument")
+	}
+	if _, ok := cons.Cdr.(*types.Nil); !ok {
+		return nil, fmt.Errorf("function requires exactly 1 argument")
+	}
+
+	if accessor, ok := setfFunctionName(cons.Car); ok {
+		fn, ok := env.setfFunctions()[accessor]
+		if !ok {
+			return nil, fmt.Errorf("undefined function: %v", cons.Car)
+		}
+		return fn, nil
+	}
+	return evalFunctionName(cons.Car, env)
+}
+
+// (setf foo)の形の関数名なら、fooを返す
+func setfFunctionName(expr types.Expr) (*types.Symbol, bool) {
+	items, err := listToSlice(expr)
+	if err != nil || len(items) != 2 {
+		return nil, false
+	}
+	if name, ok := specialFormName(items[0]); !ok || name != SpecialFormSetf {
+		return nil, false
+	}
+	accessor, ok := items[1].(*types.Symbol)
+	return accessor, ok
+}
+
 func evalLambda(args types.Expr, env *Environment) (types.Expr, error) {
-	// (lambda (params...) body)
+	// (lambda (params...) [documentation] declaration* body*)
 	// defunの構造とほとんど同じ
 	cons, ok := args.(*types.Cons)
 	if !ok {
@@ -171,29 +311,84 @@ func evalLambda(args types.Expr, env *Environment) (types.Expr, error) {
 	}
 
 	//関数本体
-	rest, ok := cons.Cdr.(*types.Cons)
-	if !ok {
-		return nil, fmt.Errorf("lambda requires a body ")
+	//先頭のドキュメント文字列とdeclareは評価しない
+	forms, err := listToSlice(cons.Cdr)
+	if err != nil {
+		return nil, fmt.Errorf("lambda: invalid body")
 	}
-
-	body := rest.Car //いったん1つの式のみ対応
+	doc, body := parseBody(forms, true)
 
 	//クロージャを作成
 	return &Lambda{
 		Params: params,
 		Body:   body,
+		Doc:    doc,
 		Env:    env, //定義時の環境を保持
 	}, nil
 }
 
-func parseParams(expr types.Expr) ([]string, error) {
+// (progn form*)
+// 順に評価して、最後の式の値を返す
+func evalProgn(args types.Expr, env *Environment) (types.Expr, error) {
+	forms, err := listToSlice(args)
+	if err != nil {
+		return nil, fmt.Errorf("progn: invalid argument list")
+	}
+	return evalBody(forms, env)
+}
+
+// bodyの式を順に評価して、最後の式の値を返す
+// 最後の式は末尾の位置なので、多値もそのまま返す
+// bodyが空ならNIL
+func evalBody(body []types.Expr, env *Environment) (types.Expr, error) {
+	var result types.Expr = &types.Nil{}
+	for _, form := range body {
+		var err error
+		if result, err = Eval(form, env); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// 関数本体の先頭にあるドキュメント文字列と(declare ...)を取り除く
+// allowDocがfalseなら、ドキュメント文字列は取り除かない
+// 本体が文字列1つだけのときは、それはドキュメントではなく返す値
+func parseBody(forms []types.Expr, allowDoc bool) (string, []types.Expr) {
+	var doc string
+	for len(forms) > 0 {
+		if str, ok := forms[0].(types.String); ok && allowDoc && doc == "" && len(forms) > 1 {
+			doc = str.Value
+			forms = forms[1:]
+			continue
+		}
+		if isDeclaration(forms[0]) {
+			forms = forms[1:]
+			continue
+		}
+		break
+	}
+	return doc, forms
+}
+
+// (declare ...)の形かどうか
+func isDeclaration(expr types.Expr) bool {
+	cons, ok := expr.(*types.Cons)
+	if !ok {
+		return false
+	}
+	name, ok := specialFormName(cons.Car)
+	return ok && name == SpecialFormDeclare
+}
+
+func parseParams(expr types.Expr) ([]*types.Symbol, error) {
 	//空リストのとき
 	// ()で渡されているとき (params..)の中身のparams...がないとき
 	if _, ok := expr.(*types.Nil); ok {
-		return []string{}, nil
+		return []*types.Symbol{}, nil
 	}
 
-	var params []string
+	var params []*types.Symbol
 	current := expr
 
 	for {
@@ -207,12 +402,12 @@ func parseParams(expr types.Expr) ([]string, error) {
 		}
 
 		//パラメータはシンボルでないといけない
-		sym, ok := cons.Car.(types.Symbol)
+		sym, ok := cons.Car.(*types.Symbol)
 		if !ok {
 			return nil, fmt.Errorf("parameter must be a symbol, got %T", cons.Car)
 		}
 
-		params = append(params, sym.Name)
+		params = append(params, sym)
 		current = cons.Cdr
 	}
 
@@ -0,0 +1,137 @@
+// リーダマクロの関数に渡す入力ストリーム
+package eval
+
+import (
+	"fmt"
+	"io"
+	"unicode"
+
+	"github.com/koplec/gospl/internal/reader"
+	"github.com/koplec/gospl/internal/types"
+)
+
+// 入力ストリーム
+// Lispのリーダマクロの関数に渡し、read-char、peek-char、readで続きを読ませる
+// 読んでいるParserから直接読むので、使えるのはリーダマクロの関数を呼んでいる間だけ
+type inputStream struct {
+	parser *reader.Parser // nilなら閉じている
+}
+
+func (s *inputStream) String() string {
+	return "#<INPUT-STREAM>"
+}
+
+// リーダマクロの関数から戻ったら閉じる
+func (s *inputStream) close() {
+	s.parser = nil
+}
+
+func registerStreamBuiltins(env *Environment) {
+	env.define("read-char", &BuiltinFunc{Name: "read-char", Fn: builtinReadChar})
+	env.define("peek-char", &BuiltinFunc{Name: "peek-char", Fn: builtinPeekChar})
+	env.define("read", &BuiltinFunc{Name: "read", Fn: builtinRead})
+}
+
+// (read-char stream &optional eof-error-p eof-value)
+// 1文字読み進めて、その文字を返す
+func builtinReadChar(args []types.Expr) (types.Expr, error) {
+	if len(args) < 1 || len(args) > 3 {
+		return nil, fmt.Errorf("read-char requires 1 to 3 arguments")
+	}
+	s, err := streamArg("read-char", args[0])
+	if err != nil {
+		return nil, err
+	}
+	r, _, err := s.parser.ReadRune()
+	if err == io.EOF {
+		return streamEOF(s, args[1:])
+	}
+	if err != nil {
+		return nil, err
+	}
+	return types.Character{Value: r}, nil
+}
+
+// (peek-char &optional peek-type stream eof-error-p eof-value)
+// 次の文字を読み進めずに返す
+// peek-typeがTなら、空白を読み飛ばしてから覗く
+func builtinPeekChar(args []types.Expr) (types.Expr, error) {
+	if len(args) < 2 || len(args) > 4 {
+		return nil, fmt.Errorf("peek-char requires a peek type and a stream")
+	}
+	s, err := streamArg("peek-char", args[1])
+	if err != nil {
+		return nil, err
+	}
+	skip := false
+	switch t := args[0].(type) {
+	case *types.Nil:
+	case types.Boolean:
+		skip = t.Value
+	default:
+		return nil, fmt.Errorf("peek-char: peek type must be T or NIL, got %v", args[0])
+	}
+
+	for {
+		r, ok := s.parser.PeekRune()
+		if !ok {
+			return streamEOF(s, args[2:])
+		}
+		if !skip || !unicode.IsSpace(r) {
+			return types.Character{Value: r}, nil
+		}
+		if _, _, err := s.parser.ReadRune(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// (read stream &optional eof-error-p eof-value recursive-p)
+// 続きの式を1つ読む
+// 式がなく入力が終わっているときだけeof-error-pを見る、式の途中で終わったときはいつもエラー
+func builtinRead(args []types.Expr) (types.Expr, error) {
+	if len(args) < 1 || len(args) > 4 {
+		return nil, fmt.Errorf("read requires 1 to 4 arguments")
+	}
+	s, err := streamArg("read", args[0])
+	if err != nil {
+		return nil, err
+	}
+	for {
+		r, ok := s.parser.PeekRune()
+		if !ok {
+			return streamEOF(s, args[1:])
+		}
+		if !unicode.IsSpace(r) {
+			break
+		}
+		if _, _, err := s.parser.ReadRune(); err != nil {
+			return nil, err
+		}
+	}
+	return s.parser.Parse()
+}
+
+// 入力が終わったときの値
+// eof-error-pがNILならeof-value（省略するとNIL）、そうでなければ続きの入力を待つ構文エラー
+func streamEOF(s *inputStream, args []types.Expr) (types.Expr, error) {
+	if len(args) >= 1 && !isTrue(args[0]) {
+		if len(args) >= 2 {
+			return args[1], nil
+		}
+		return &types.Nil{}, nil
+	}
+	return nil, s.parser.EOFError()
+}
+
+// 引数が読める入力ストリームであることを確認して返す
+func streamArg(fn string, arg types.Expr) (*inputStream, error) {
+	s, ok := arg.(*inputStream)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an input stream: %v", fn, arg)
+	}
+	if s.parser == nil {
+		return nil, fmt.Errorf("%s: stream is closed", fn)
+	}
+	return s, nil
+}
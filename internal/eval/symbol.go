@@ -0,0 +1,172 @@
+// シンボルの同一性と、値セルや関数セル、属性リストを扱う組み込み関数
+package eval
+
+import (
+	"fmt"
+
+	"github.com/koplec/gospl/internal/types"
+)
+
+func registerSymbolBuiltins(env *Environment) {
+	env.define("eq", &BuiltinFunc{Name: "eq", Fn: builtinEq})
+	env.define("eql", equalityBuiltin("eql", types.Eql))
+	env.define("equal", equalityBuiltin("equal", types.Equal))
+	env.define("equalp", equalityBuiltin("equalp", types.Equalp))
+	env.define("symbolp", &BuiltinFunc{Name: "symbolp", Fn: builtinSymbolp})
+	env.define("symbol-value", &BuiltinFunc{Name: "symbol-value", Fn: builtinSymbolValue})
+	env.define("boundp", &BuiltinFunc{Name: "boundp", Fn: builtinBoundp})
+	env.define("symbol-function", &BuiltinFunc{Name: "symbol-function", Fn: builtinSymbolFunction})
+	env.define("fboundp", &BuiltinFunc{Name: "fboundp", Fn: builtinFboundp})
+	env.define("fmakunbound", &BuiltinFunc{Name: "fmakunbound", Fn: builtinFmakunbound})
+	env.define("symbol-plist", &BuiltinFunc{Name: "symbol-plist", Fn: builtinSymbolPlist})
+	env.define("get", &BuiltinFunc{Name: "get", Fn: builtinGet})
+	env.define("remprop", &BuiltinFunc{Name: "remprop", Fn: builtinRemprop})
+}
+
+// (eq x y)
+// 同じオブジェクトならT
+// 同じ名前のシンボルは、インターンされているので常にeq
+func builtinEq(args []types.Expr) (types.Expr, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("eq requires exactly 2 arguments")
+	}
+	return types.Boolean{Value: types.Eq(args[0], args[1])}, nil
+}
+
+// (eql x y) (equal x y) (equalp x y)
+func equalityBuiltin(name string, equal func(a, b types.Expr) bool) *BuiltinFunc {
+	return &BuiltinFunc{Name: name, Fn: func(args []types.Expr) (types.Expr, error) {
+		if len(args) != 2 {
+			return nil, fmt.Errorf("%s requires exactly 2 arguments", name)
+		}
+		return types.Boolean{Value: equal(args[0], args[1])}, nil
+	}}
+}
+
+// (symbolp x)
+// nilとtも、Common Lispと同じようにシンボルとみなす
+func builtinSymbolp(args []types.Expr) (types.Expr, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("symbolp requires exactly 1 argument")
+	}
+	switch args[0].(type) {
+	case *types.Symbol, *types.Nil, types.Boolean:
+		return types.Boolean{Value: true}, nil
+	default:
+		return types.Boolean{Value: false}, nil
+	}
+}
+
+// (symbol-value symbol)
+// グローバルな値（値セル）を返す
+func builtinSymbolValue(args []types.Expr) (types.Expr, error) {
+	sym, err := symbolArg("symbol-value", args)
+	if err != nil {
+		return nil, err
+	}
+	if sym.Value == nil {
+		return nil, fmt.Errorf("symbol-value: unbound variable: %s", sym)
+	}
+	return sym.Value, nil
+}
+
+// (boundp symbol)
+func builtinBoundp(args []types.Expr) (types.Expr, error) {
+	sym, err := symbolArg("boundp", args)
+	if err != nil {
+		return nil, err
+	}
+	return types.Boolean{Value: sym.Value != nil}, nil
+}
+
+// (symbol-function symbol)
+// グローバルな関数（関数セル）を返す
+func builtinSymbolFunction(args []types.Expr) (types.Expr, error) {
+	sym, err := symbolArg("symbol-function", args)
+	if err != nil {
+		return nil, err
+	}
+	fn, err := globalFunction(sym)
+	if err != nil {
+		return nil, fmt.Errorf("symbol-function: %w", err)
+	}
+	return fn, nil
+}
+
+// (fboundp symbol)
+// グローバルな関数があるか、特殊形式の名前ならT
+func builtinFboundp(args []types.Expr) (types.Expr, error) {
+	sym, err := symbolArg("fboundp", args)
+	if err != nil {
+		return nil, err
+	}
+	_, special := specialFormName(sym)
+	return types.Boolean{Value: sym.Function != nil || special}, nil
+}
+
+// (fmakunbound symbol)
+// グローバルな関数を取り除いて、symbolを返す
+func builtinFmakunbound(args []types.Expr) (types.Expr, error) {
+	sym, err := symbolArg("fmakunbound", args)
+	if err != nil {
+		return nil, err
+	}
+	sym.Function = nil
+	return sym, nil
+}
+
+// (symbol-plist symbol)
+func builtinSymbolPlist(args []types.Expr) (types.Expr, error) {
+	sym, err := symbolArg("symbol-plist", args)
+	if err != nil {
+		return nil, err
+	}
+	if sym.Plist == nil {
+		return &types.Nil{}, nil
+	}
+	return sym.Plist, nil
+}
+
+// (get symbol indicator &optional default)
+// 属性リストにindicatorがなければdefault（省略するとNIL）
+func builtinGet(args []types.Expr) (types.Expr, error) {
+	if len(args) < 2 || len(args) > 3 {
+		return nil, fmt.Errorf("get requires 2 or 3 arguments")
+	}
+	sym, err := symbolArg("get", args[:1])
+	if err != nil {
+		return nil, err
+	}
+	if value, ok := sym.Get(args[1]); ok {
+		return value, nil
+	}
+	if len(args) == 3 {
+		return args[2], nil
+	}
+	return &types.Nil{}, nil
+}
+
+// (remprop symbol indicator)
+// 取り除いたときはT、なかったときはNIL
+func builtinRemprop(args []types.Expr) (types.Expr, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("remprop requires exactly 2 arguments")
+	}
+	sym, err := symbolArg("remprop", args[:1])
+	if err != nil {
+		return nil, err
+	}
+	return types.Boolean{Value: sym.Remove(args[1])}, nil
+}
+
+// 引数がシンボル1つであることを確認して返す
+func symbolArg(fn string, args []types.Expr) (*types.Symbol, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("%s requires exactly 1 argument", fn)
+	}
+	sym, ok := args[0].(*types.Symbol)
+	if !ok {
+		return nil, fmt.Errorf("%s: not a symbol: %v", fn, args[0])
+	}
+	return sym, nil
+}
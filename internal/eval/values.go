@@ -0,0 +1,132 @@
+// 多値
+package eval
+
+import (
+	"fmt"
+
+	"github.com/koplec/gospl/internal/types"
+)
+
+func registerValuesBuiltins(env *Environment) {
+	env.define("values", &BuiltinFunc{Name: "values", Fn: builtinValues})
+	env.define("values-list", &BuiltinFunc{Name: "values-list", Fn: builtinValuesList})
+}
+
+// (values object*)
+// 引数をそのまま多値として返す
+func builtinValues(args []types.Expr) (types.Expr, error) {
+	return types.NewValues(args...), nil
+}
+
+// (values-list list)
+// リストの要素を多値として返す
+func builtinValuesList(args []types.Expr) (types.Expr, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("values-list requires exactly 1 argument")
+	}
+	values, err := listToSlice(args[0])
+	if err != nil {
+		return nil, fmt.Errorf("values-list: not a list: %v", args[0])
+	}
+	return types.NewValues(values...), nil
+}
+
+// (multiple-value-bind (var*) values-form body*)
+// values-formの値を順にvarに束縛してbodyを評価する
+// 値が足りないvarはNILになり、余った値は捨てる
+func evalMultipleValueBind(args types.Expr, env *Environment) (types.Expr, error) {
+	items, err := listToSlice(args)
+	if err != nil || len(items) < 2 {
+		return nil, fmt.Errorf("multiple-value-bind requires a variable list and a values form")
+	}
+	vars, err := parseParams(items[0])
+	if err != nil {
+		return nil, fmt.Errorf("multiple-value-bind: %v", err)
+	}
+
+	result, err := Eval(items[1], env)
+	if err != nil {
+		return nil, err
+	}
+	values := types.ValueList(result)
+
+	newEnv := NewEnvironment(env)
+	var dyn dynamicBindings
+	defer dyn.restore()
+	for i, v := range vars {
+		var value types.Expr = &types.Nil{}
+		if i < len(values) {
+			value = values[i]
+		}
+		if err := newEnv.bind(v, value, &dyn); err != nil {
+			return nil, fmt.Errorf("multiple-value-bind: %w", err)
+		}
+	}
+
+	_, body := parseBody(items[2:], false)
+	return evalBody(body, newEnv)
+}
+
+// (multiple-value-list form)
+// formのすべての値をリストにする
+func evalMultipleValueList(args types.Expr, env *Environment) (types.Expr, error) {
+	items, err := listToSlice(args)
+	if err != nil || len(items) != 1 {
+		return nil, fmt.Errorf("multiple-value-list requires exactly 1 argument")
+	}
+	result, err := Eval(items[0], env)
+	if err != nil {
+		return nil, err
+	}
+	return sliceToList(types.ValueList(result)), nil
+}
+
+// (multiple-value-call function form*)
+// それぞれのformのすべての値を順に並べて、functionの引数にする
+func evalMultipleValueCall(args types.Expr, env *Environment) (types.Expr, error) {
+	items, err := listToSlice(args)
+	if err != nil || len(items) == 0 {
+		return nil, fmt.Errorf("multiple-value-call requires a function")
+	}
+	fn, err := evalPrimary(items[0], env)
+	if err != nil {
+		return nil, err
+	}
+
+	var fnArgs []types.Expr
+	for _, form := range items[1:] {
+		result, err := Eval(form, env)
+		if err != nil {
+			return nil, err
+		}
+		fnArgs = append(fnArgs, types.ValueList(result)...)
+	}
+	return apply(fn, fnArgs)
+}
+
+// (nth-value n form)
+// formのn番目（0から数える）の値、なければNIL
+func evalNthValue(args types.Expr, env *Environment) (types.Expr, error) {
+	items, err := listToSlice(args)
+	if err != nil || len(items) != 2 {
+		return nil, fmt.Errorf("nth-value requires exactly 2 arguments")
+	}
+	n, err := evalPrimary(items[0], env)
+	if err != nil {
+		return nil, err
+	}
+	index, ok := n.(types.Fixnum)
+	if !ok || index.Value < 0 {
+		return nil, fmt.Errorf("nth-value: not a non-negative integer: %v", n)
+	}
+
+	result, err := Eval(items[1], env)
+	if err != nil {
+		return nil, err
+	}
+	values := types.ValueList(result)
+	if index.Value >= int64(len(values)) {
+		return &types.Nil{}, nil
+	}
+	return values[index.Value], nil
+}
@@ -0,0 +1,246 @@
+package reader
+
+import (
+	"io"
+	"strings"
+)
+
+// CST(具象構文木)
+// Parserが捨ててしまう空白やコメント、トークンの元の文字列をすべて残した構文木
+// フォーマッタやリファクタリングツール、リンタのように、ソースをそのまま扱いたいときに使う
+// CSTを文字列に戻すと、元のソースと1バイトも違わない
+
+// トークンの前の空白とコメントの種類
+type TriviaKind int
+
+const (
+	Whitespace   TriviaKind = iota // 空白と改行
+	LineComment                    // ; から行末まで（改行は含まない）
+	BlockComment                   // #| ... |#
+)
+
+// トークンの前にある空白やコメント
+type Trivia struct {
+	Kind TriviaKind
+	Text string   // ソース上の文字列そのまま
+	Pos  Position // 始まりの位置
+}
+
+// CSTの葉になるトークン
+type Leaf struct {
+	Token   Token
+	Text    string   // ソース上のトークンの文字列そのまま（"a\nb"のエスケープも元のまま）
+	Leading []Trivia // トークンの前の空白とコメント
+}
+
+// CSTのノードの種類
+type NodeKind int
+
+const (
+	AtomNode   NodeKind = iota // 数値、文字列、文字、シンボル
+	ListNode                   // ( ... ) や #( ... )
+	PrefixNode                 // 'x `x ,x ,@x #;x #2Ax のように、後ろの式を1つとるマクロ文字
+	DotNode                    // (a . b)の.
+)
+
+// CSTのノード
+type Node struct {
+	Kind     NodeKind
+	Leaf     Leaf    // アトムやドットならそのトークン、リストなら'('、PrefixNodeならマクロ文字
+	Children []*Node // リストの要素（DotNodeも含む）、PrefixNodeなら後ろの式1つ
+	Close    *Leaf   // リストの')'
+}
+
+// 1つのソース全体のCST
+type File struct {
+	Nodes    []*Node
+	Trailing []Trivia // 最後の式のあとの空白とコメント
+}
+
+// 元のソースの文字列に戻す
+func (f *File) String() string {
+	var sb strings.Builder
+	for _, n := range f.Nodes {
+		n.write(&sb)
+	}
+	writeTrivia(&sb, f.Trailing)
+	return sb.String()
+}
+
+// ノードの元のソースの文字列に戻す
+// ノードの前の空白とコメントも含む
+func (n *Node) String() string {
+	var sb strings.Builder
+	n.write(&sb)
+	return sb.String()
+}
+
+func (n *Node) write(sb *strings.Builder) {
+	n.Leaf.write(sb)
+	for _, child := range n.Children {
+		child.write(sb)
+	}
+	if n.Close != nil {
+		n.Close.write(sb)
+	}
+}
+
+func (l *Leaf) write(sb *strings.Builder) {
+	writeTrivia(sb, l.Leading)
+	sb.WriteString(l.Text)
+}
+
+func writeTrivia(sb *strings.Builder, trivia []Trivia) {
+	for _, t := range trivia {
+		sb.WriteString(t.Text)
+	}
+}
+
+// CSTを作るParser
+// Parserと同じLexerでトークンに分けるが、式には変換せずにトークンと空白やコメントを木にする
+// リードテーブルのマクロ文字は、後ろの式を1つとるものとして扱う
+type CSTParser struct {
+	lexer   *Lexer
+	current Leaf
+}
+
+func NewCSTParser(input string) *CSTParser {
+	return newCSTParser(NewLexer(input))
+}
+
+// io.Readerから読むCSTParserを生成する
+func NewCSTParserFromReader(r io.Reader) *CSTParser {
+	return newCSTParser(NewLexerFromReader(r))
+}
+
+func newCSTParser(lexer *Lexer) *CSTParser {
+	lexer.raw = &strings.Builder{}
+	return &CSTParser{lexer: lexer}
+}
+
+// 使うリードテーブルを切り替える
+func (p *CSTParser) SetReadtable(rt *Readtable) {
+	p.lexer.readtable = rt
+}
+
+// 入力全体を読んでCSTにする
+func (p *CSTParser) Parse() (*File, error) {
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	file := &File{}
+	for p.current.Token.Type != EOF {
+		node, err := p.parseNode()
+		if err != nil {
+			return nil, err
+		}
+		file.Nodes = append(file.Nodes, node)
+	}
+	file.Trailing = p.current.Leading
+	return file, nil
+}
+
+// 文字列を読んでCSTにする
+func ParseCST(input string) (*File, error) {
+	return NewCSTParser(input).Parse()
+}
+
+// 現在のトークンから始まるノードを1つ読む
+func (p *CSTParser) parseNode() (*Node, error) {
+	switch p.current.Token.Type {
+	case NUMBER, STRING, SYMBOL, CHARACTER:
+		return p.leaf(AtomNode)
+	case LPAREN, VECTOR:
+		return p.parseList()
+	case QUOTE, BACKQUOTE, COMMA, COMMA_AT, DATUM_COMMENT, MACRO, DISPATCH, ARRAY:
+		return p.parsePrefix()
+	case EOF:
+		return nil, p.syntaxError(UnexpectedEOF, "")
+	default:
+		return nil, p.syntaxError(UnexpectedToken, "")
+	}
+}
+
+// 現在のトークンだけのノードにして、次のトークンに進む
+func (p *CSTParser) leaf(kind NodeKind) (*Node, error) {
+	node := &Node{Kind: kind, Leaf: p.current}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+func (p *CSTParser) parseList() (*Node, error) {
+	node := &Node{Kind: ListNode, Leaf: p.current}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	for p.current.Token.Type != RPAREN {
+		var child *Node
+		var err error
+		switch p.current.Token.Type {
+		case EOF:
+			return nil, p.syntaxError(UnexpectedEOF, "')'")
+		case DOT:
+			child, err = p.leaf(DotNode)
+		default:
+			child, err = p.parseNode()
+		}
+		if err != nil {
+			return nil, err
+		}
+		node.Children = append(node.Children, child)
+	}
+
+	closing := p.current
+	node.Close = &closing
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+func (p *CSTParser) parsePrefix() (*Node, error) {
+	node := &Node{Kind: PrefixNode, Leaf: p.current}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	switch p.current.Token.Type {
+	case EOF:
+		return nil, p.syntaxError(UnexpectedEOF, "an expression after '"+node.Leaf.Text+"'")
+	case RPAREN, DOT:
+		return nil, p.syntaxError(MissingExpression, "an expression after '"+node.Leaf.Text+"'")
+	}
+
+	operand, err := p.parseNode()
+	if err != nil {
+		return nil, err
+	}
+	node.Children = []*Node{operand}
+	return node, nil
+}
+
+// 次のトークンを、その文字列と前の空白やコメントと一緒に読む
+func (p *CSTParser) next() error {
+	token, err := p.lexer.NextToken()
+	if err != nil {
+		return err
+	}
+	p.current = Leaf{Token: token, Text: p.lexer.raw.String(), Leading: p.lexer.trivia}
+	p.lexer.raw.Reset()
+	p.lexer.trivia = nil
+	return nil
+}
+
+// 現在のトークンの位置の構文エラーを作る
+func (p *CSTParser) syntaxError(kind SyntaxErrorKind, expected string) *SyntaxError {
+	return &SyntaxError{
+		Kind:     kind,
+		Pos:      p.current.Token.Pos,
+		Expected: expected,
+		Found:    describeToken(p.current.Token),
+	}
+}
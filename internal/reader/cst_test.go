@@ -0,0 +1,101 @@
+package reader
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCST_RoundTrip(t *testing.T) {
+	tests := []string{
+		"",
+		"   \n\t",
+		"; only a comment",
+		"(+ 1 2)",
+		"  (defun  f (x)\n    ;; 二乗する\n    (* x x))  ; 末尾のコメント\n",
+		"#| block #| nested |# |# 'a `(b ,c ,@d) #;(ignored) e",
+		"(a . b) (1 2 . 3)",
+		`"escaped \"quote\" あ \n" 1.5d0 #x1F 1/3 +.5`,
+		"cl:car :key pkg::x",
+		`#\a #\Space #\( #\あ`,
+		"#( 1 #(2) ) #2A((1 2) ; row\n (3 4))",
+		"(funcall #'car '(1)) #' (lambda (x) x)",
+		"\r\n(a\r\n b)　あいう ",
+		"(a \xff b) \"\xfe\" ; \xc3\n\xef\xbf\xbd",
+	}
+
+	for _, input := range tests {
+		t.Run(input, func(t *testing.T) {
+			file, err := ParseCST(input)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := file.String(); got != input {
+				t.Errorf("expected %q, got %q", input, got)
+			}
+		})
+	}
+}
+
+func TestCST_Structure(t *testing.T) {
+	file, err := ParseCST("; head\n(f 'x ; why\n . y) ; tail\n")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(file.Nodes) != 1 {
+		t.Fatalf("expected 1 node, got %d", len(file.Nodes))
+	}
+
+	list := file.Nodes[0]
+	if list.Kind != ListNode || len(list.Children) != 4 {
+		t.Fatalf("expected a list with 4 children, got %v with %d", list.Kind, len(list.Children))
+	}
+	if len(list.Leaf.Leading) != 2 || list.Leaf.Leading[0].Kind != LineComment || list.Leaf.Leading[0].Text != "; head" {
+		t.Errorf("expected leading comment '; head', got %+v", list.Leaf.Leading)
+	}
+
+	quote := list.Children[1]
+	if quote.Kind != PrefixNode || quote.Leaf.Text != "'" || quote.Children[0].Leaf.Text != "x" {
+		t.Errorf("expected 'x, got %s", quote)
+	}
+
+	dot := list.Children[2]
+	if dot.Kind != DotNode {
+		t.Errorf("expected DotNode, got %v", dot.Kind)
+	}
+	if len(dot.Leaf.Leading) != 3 || dot.Leaf.Leading[1].Text != "; why" {
+		t.Errorf("expected '; why' before '.', got %+v", dot.Leaf.Leading)
+	}
+	if dot.Leaf.Token.Pos != (Position{Line: 3, Column: 2}) {
+		t.Errorf("expected '.' at 3:2, got %v", dot.Leaf.Token.Pos)
+	}
+
+	if len(file.Trailing) != 3 || file.Trailing[1].Text != "; tail" {
+		t.Errorf("expected trailing '; tail', got %+v", file.Trailing)
+	}
+}
+
+func TestCST_Errors(t *testing.T) {
+	tests := []struct {
+		input string
+		kind  SyntaxErrorKind
+	}{
+		{"(a b", UnexpectedEOF},
+		{")", UnexpectedToken},
+		{"'", UnexpectedEOF},
+		{"(a ')", MissingExpression},
+		{`"abc`, UnterminatedString},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := ParseCST(tt.input)
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("expected *SyntaxError, got %v", err)
+			}
+			if syntaxErr.Kind != tt.kind {
+				t.Errorf("expected %v, got %v", tt.kind, syntaxErr.Kind)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package reader
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 構文エラーの種類
+type SyntaxErrorKind int
+
+const (
+	UnexpectedCharacter SyntaxErrorKind = iota // 読めない文字 | \ #
+	UnterminatedString                         // "が閉じていない
+	UnterminatedComment                        // #|が閉じていない
+	InvalidEscape                              // 文字列中の\u12のような不正なエスケープ
+	InvalidNumber                              // 1/0 #b102のような不正な数値
+	InvalidToken                               // ..のような読めないトークン
+	UnexpectedEOF                              // 式の途中で入力が終わった
+	UnexpectedToken                            // 式の始まりや終わりに来てはいけないトークン
+	MissingExpression                          // #;や.のあとに式がない
+	InvalidSymbol                              // a:b:cや::xのような不正なシンボル
+	UnknownPackage                             // パッケージ修飾子のパッケージがない
+	SymbolNotExternal                          // pkg:nameのnameが外部シンボルでない
+	InvalidCharacter                           // #\fooのような名前のない文字
+	InvalidArray                               // #2A(1 (2 3))のように中身が次元に合わない配列
+	UnknownMacro                               // 読んだ後にリードテーブルから消えたマクロ文字
+	InvalidMacroResult                         // リーダマクロの関数が式を返さなかった
+)
+
+func (k SyntaxErrorKind) String() string {
+	switch k {
+	case UnexpectedCharacter:
+		return "unexpected character"
+	case UnterminatedString:
+		return "unterminated string"
+	case UnterminatedComment:
+		return "unterminated block comment"
+	case InvalidEscape:
+		return "invalid escape sequence"
+	case InvalidNumber:
+		return "invalid number"
+	case InvalidToken:
+		return "invalid token"
+	case UnexpectedEOF:
+		return "unexpected end of input"
+	case UnexpectedToken:
+		return "unexpected token"
+	case MissingExpression:
+		return "missing expression"
+	case InvalidSymbol:
+		return "invalid symbol"
+	case UnknownPackage:
+		return "unknown package"
+	case SymbolNotExternal:
+		return "symbol is not external"
+	case InvalidCharacter:
+		return "unknown character name"
+	case InvalidArray:
+		return "invalid array literal"
+	case UnknownMacro:
+		return "no reader macro"
+	case InvalidMacroResult:
+		return "reader macro returned no value"
+	default:
+		return fmt.Sprintf("SyntaxErrorKind(%d)", int(k))
+	}
+}
+
+// LexerやParserが返す構文エラー
+// errors.Asで取り出して、種類や位置を調べられる
+type SyntaxError struct {
+	Kind     SyntaxErrorKind
+	File     string   // ファイル名（わからないときは空）
+	Pos      Position // エラーの位置
+	Expected string   // 期待していたもの（')'など）、特にないときは空
+	Found    string   // 実際に読んだもの（'x'やEOFなど）
+}
+
+// file:line:column: 種類: expected X, found Y の形
+func (e *SyntaxError) Error() string {
+	var sb strings.Builder
+	if e.File != "" {
+		sb.WriteString(e.File + ":")
+	}
+	fmt.Fprintf(&sb, "%d:%d: %s", e.Pos.Line, e.Pos.Column, e.Kind)
+
+	switch {
+	case e.Expected != "":
+		fmt.Fprintf(&sb, ": expected %s, found %s", e.Expected, e.Found)
+	case e.Found != "":
+		fmt.Fprintf(&sb, ": %s", e.Found)
+	}
+	return sb.String()
+}
+
+// 構文エラーの一覧
+// エラーから回復しながら読んだときに、見つかったエラーをまとめて返す
+type ErrorList []*SyntaxError
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	default:
+		return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+	}
+}
+
+// errors.Asやerrors.Isで、中のSyntaxErrorを調べられるようにする
+func (l ErrorList) Unwrap() []error {
+	errs := make([]error, len(l))
+	for i, e := range l {
+		errs[i] = e
+	}
+	return errs
+}
+
+// エラーがなければnil、あればErrorListを返す
+func (l ErrorList) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
+
+// 続きを入力すれば読める途中の式で終わったためのエラーかどうか
+// REPLで、複数行にわたる式の入力を続けるかどうかの判断に使う
+func IsIncomplete(err error) bool {
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		return false
+	}
+	switch syntaxErr.Kind {
+	case UnexpectedEOF, UnterminatedString, UnterminatedComment:
+		return true
+	default:
+		return false
+	}
+}
+
+// トークンをエラーメッセージ用の文字列にする
+func describeToken(token Token) string {
+	if token.Type == EOF {
+		return "EOF"
+	}
+	return fmt.Sprintf("'%s'", token.Value)
+}
@@ -0,0 +1,252 @@
+package reader
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/koplec/gospl/internal/types"
+)
+
+func TestSyntaxError_Kinds(t *testing.T) {
+	tests := []struct {
+		input    string
+		kind     SyntaxErrorKind
+		pos      Position
+		expected string
+		found    string
+	}{
+		{"(a b", UnexpectedEOF, Position{1, 5}, "')'", "EOF"},
+		{")", UnexpectedToken, Position{1, 1}, "", "')'"},
+		{"(a\n  |b)", UnexpectedCharacter, Position{2, 3}, "", "'|'"},
+		{`"abc`, UnterminatedString, Position{1, 1}, `'"'`, "EOF"},
+		{"#| abc", UnterminatedComment, Position{1, 1}, "'|#'", "EOF"},
+		{`"\u12x"`, InvalidEscape, Position{1, 2}, `4 hex digits after \u`, "'x'"},
+		{"(1 #b102)", InvalidNumber, Position{1, 4}, "", "'#b102'"},
+		{"1/0", InvalidNumber, Position{1, 1}, "", "'1/0'"},
+		{"..", InvalidToken, Position{1, 1}, "", "'..'"},
+		{`(#\foo)`, InvalidCharacter, Position{1, 2}, "", `'#\foo'`},
+		{`#\U+D800`, InvalidCharacter, Position{1, 1}, "", `'#\U+D800'`},
+		{"#2A((1 2) (3))", InvalidArray, Position{1, 1}, "", "'#2A((1 2) (3))'"},
+		{"#(1 . 2)", UnexpectedToken, Position{1, 1}, "a vector without a dotted tail", "'.'"},
+		{"(1 . 2 3)", UnexpectedToken, Position{1, 8}, "')' after dotted pair", "'3'"},
+		{"(1 . )", MissingExpression, Position{1, 6}, "an expression after '.'", "')'"},
+		{"(a #;)", MissingExpression, Position{1, 6}, "an expression after '#;'", "')'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := NewParser(tt.input).Parse()
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("expected *SyntaxError, got %T: %v", err, err)
+			}
+			if syntaxErr.Kind != tt.kind {
+				t.Errorf("kind: expected %v, got %v", tt.kind, syntaxErr.Kind)
+			}
+			if syntaxErr.Pos != tt.pos {
+				t.Errorf("pos: expected %v, got %v", tt.pos, syntaxErr.Pos)
+			}
+			if syntaxErr.Expected != tt.expected {
+				t.Errorf("expected: expected %q, got %q", tt.expected, syntaxErr.Expected)
+			}
+			if syntaxErr.Found != tt.found {
+				t.Errorf("found: expected %q, got %q", tt.found, syntaxErr.Found)
+			}
+		})
+	}
+}
+
+func TestSyntaxError_Message(t *testing.T) {
+	_, err := NewFileReader(strings.NewReader("(a\n (b"), "test.lisp").Read()
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	expected := "test.lisp:2:4: unexpected end of input: expected ')', found EOF"
+	if err.Error() != expected {
+		t.Errorf("expected %q, got %q", expected, err.Error())
+	}
+}
+
+func TestParseAll_Recovery(t *testing.T) {
+	input := `(defun f (x) (+ x | 1))
+)
+(g "\u12" 2)
+(h #b102 . 3 4)
+(ok)
+(last`
+
+	parser := NewParser(input)
+	parser.SetRecovery(true)
+	exprs, err := parser.ParseAll()
+
+	var list ErrorList
+	if !errors.As(err, &list) {
+		t.Fatalf("expected ErrorList, got %T: %v", err, err)
+	}
+
+	expected := []struct {
+		kind SyntaxErrorKind
+		pos  Position
+	}{
+		{UnexpectedCharacter, Position{1, 19}},
+		{UnexpectedToken, Position{2, 1}},
+		{InvalidEscape, Position{3, 5}},
+		{InvalidNumber, Position{4, 4}},
+		{UnexpectedToken, Position{4, 14}},
+		{UnexpectedEOF, Position{6, 6}},
+	}
+	if len(list) != len(expected) {
+		t.Fatalf("expected %d errors, got %d: %v", len(expected), len(list), list.Unwrap())
+	}
+	for i, e := range expected {
+		if list[i].Kind != e.kind || list[i].Pos != e.pos {
+			t.Errorf("error %d: expected %v at %v, got %v", i, e.kind, e.pos, list[i])
+		}
+	}
+
+	// エラーのあった要素だけを読み飛ばして、残りは読めている
+	want := []string{"(defun f (x) (+ x 1))", "(g 2)", "(h . 3)", "(ok)"}
+	if len(exprs) != len(want) {
+		t.Fatalf("expected %d exprs, got %d", len(want), len(exprs))
+	}
+	for i, w := range want {
+		if exprs[i].String() != w {
+			t.Errorf("expr %d: expected %s, got %s", i, w, exprs[i].String())
+		}
+	}
+}
+
+func TestReader_Recovery(t *testing.T) {
+	r := NewReader(strings.NewReader("(a) ) (b |) (c)"))
+	r.SetRecovery(true)
+
+	var got []string
+	for {
+		form, err := r.Read()
+		if err != nil {
+			break
+		}
+		got = append(got, form.Expr.String())
+	}
+
+	if strings.Join(got, " ") != "(a) (b) (c)" {
+		t.Errorf("expected (a) (b) (c), got %v", got)
+	}
+	if len(r.Errors()) != 2 {
+		t.Errorf("expected 2 errors, got %v", r.Errors())
+	}
+}
+
+// リーダマクロのエラーも構文エラーとして記録し、読み続ける
+func TestParseAll_RecoveryReaderMacro(t *testing.T) {
+	rt := NewReadtable()
+	noValue := func(p *Parser, text string) (types.Expr, error) {
+		return nil, nil
+	}
+	if err := rt.SetDispatchMacroCharacter('#', "n", noValue); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	parser := NewFileParser(strings.NewReader("(a #n b)\n(c)\n#z (d)"), "macro.lisp")
+	parser.SetReadtable(rt)
+	parser.SetRecovery(true)
+	exprs, err := parser.ParseAll()
+
+	var list ErrorList
+	if !errors.As(err, &list) {
+		t.Fatalf("expected ErrorList, got %T: %v", err, err)
+	}
+	expected := []struct {
+		kind SyntaxErrorKind
+		pos  Position
+	}{
+		{InvalidMacroResult, Position{1, 4}},
+		{UnexpectedCharacter, Position{3, 1}},
+	}
+	if len(list) != len(expected) {
+		t.Fatalf("expected %d errors, got %d: %v", len(expected), len(list), list.Unwrap())
+	}
+	for i, e := range expected {
+		if list[i].Kind != e.kind || list[i].Pos != e.pos || list[i].File != "macro.lisp" {
+			t.Errorf("error %d: expected %v at %v, got %v", i, e.kind, e.pos, list[i])
+		}
+	}
+
+	var got []string
+	for _, expr := range exprs {
+		got = append(got, expr.String())
+	}
+	if strings.Join(got, " ") != "(a b) (c) z (d)" {
+		t.Errorf("expected (a b) (c) z (d), got %v", got)
+	}
+}
+
+// 読み込んだ後でリードテーブルから消えたマクロ文字や、トークンを読み込んだ後のReadRuneも構文エラーにする
+func TestSyntaxError_ReaderMacroState(t *testing.T) {
+	rt := NewReadtable()
+	readAfterToken := func(p *Parser, text string) (types.Expr, error) {
+		if err := p.fill(); err != nil {
+			return nil, err
+		}
+		_, _, err := p.ReadRune()
+		return nil, err
+	}
+	if err := rt.SetDispatchMacroCharacter('#', "r", readAfterToken); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := rt.SetDispatchMacroCharacter('#', "z", readAfterToken); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	parser := NewParser("#r x")
+	parser.SetReadtable(rt)
+	_, err := parser.Parse()
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) || syntaxErr.Kind != UnexpectedToken || syntaxErr.Pos != (Position{1, 4}) {
+		t.Errorf("ReadRune: expected unexpected token at 1:4, got %v", err)
+	}
+
+	parser = NewParser("#z")
+	parser.SetReadtable(rt)
+	if err := parser.fill(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	delete(rt.dispatch['#'], "z")
+	_, err = parser.Parse()
+	if !errors.As(err, &syntaxErr) || syntaxErr.Kind != UnknownMacro || syntaxErr.Pos != (Position{1, 1}) {
+		t.Errorf("lookup: expected no reader macro at 1:1, got %v", err)
+	}
+}
+
+func TestIsIncomplete(t *testing.T) {
+	tests := []struct {
+		input      string
+		incomplete bool
+	}{
+		{"(defun f (x)", true},
+		{"(a . ", true},
+		{"'", true},
+		{"(a #;", true},
+		{`"abc`, true},
+		{"#| abc", true},
+		{`#\`, true},
+		{"#(1 2", true},
+		{"#2A", true},
+		{")", false},
+		{"(a |)", false},
+		{"1/0", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := NewParser(tt.input).Parse()
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if IsIncomplete(err) != tt.incomplete {
+				t.Errorf("expected %v, got %v: %v", tt.incomplete, IsIncomplete(err), err)
+			}
+		})
+	}
+}
@@ -1,16 +1,36 @@
 package reader
 
-import "fmt"
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/koplec/gospl/internal/types"
+)
 
 type TokenType int
 
 const (
-	LPAREN TokenType = iota // (
-	RPAREN                  // )
-	NUMBER                  // 123, 3.14
-	STRING                  // "hello"
-	SYMBOL                  // foo, +, defun
-	QUOTE                   // '
+	LPAREN        TokenType = iota // (
+	RPAREN                         // )
+	NUMBER                         // 123, 3.14, 1e10, 1/3, #x1F
+	STRING                         // "hello"
+	SYMBOL                         // foo, +, defun
+	QUOTE                          // '
+	BACKQUOTE                      // `
+	COMMA                          // ,
+	COMMA_AT                       // ,@
+	DOT                            // . (ドット対の区切り)
+	DATUM_COMMENT                  // #; 次の式を読み飛ばす
+	MACRO                          // リードテーブルに登録されたマクロ文字
+	DISPATCH                       // リードテーブルに登録された#のあとのサブ文字 #date
+	CHARACTER                      // #\a #\Space
+	VECTOR                         // #( ベクタの始まり、')'で閉じる
+	ARRAY                          // #2A 次の式を2次元配列の中身として読む
 	EOF
 	ILLEGAL
 )
@@ -20,9 +40,11 @@ type Token struct {
 	Type  TokenType
 	Value string
 	Pos   Position
+	End   Position // トークンの最後の文字の次の位置
 }
 
 // Lexerが読んでいるソースコード上の位置
+// Columnはバイト数ではなく文字数で数える（"あいう"の次は4列目）
 type Position struct {
 	Line   int
 	Column int
@@ -30,37 +52,70 @@ type Position struct {
 
 // 字句解析機
 // プログラムのソースコードをTOKENに分解する
+// 入力はio.Readerから少しずつ読むので、大きなファイルでも全体を文字列にしなくてよい
 type Lexer struct {
-	input  string
-	pos    int // inputの中で現在読んでいる位置、input全体の中でどの位置か
-	line   int // 現在読んでいる行番号（1始まり）
-	column int // 現在読んでいる列番号（1始まり）
+	src       *bufio.Reader
+	readtable *Readtable  // どの文字がマクロ文字か
+	ahead     []aheadRune // peekで先読みしたまだ読み進めていない文字
+	err       error       // 入力の読み込みで起きたio.EOF以外のエラー
+	pos       int         // 入力の先頭から何バイト読んだか
+	line      int         // 現在読んでいる行番号（1始まり）
+	column    int         // 現在読んでいる列番号（1始まり）
+
+	raw    *strings.Builder // CSTを作るときだけ、読み進めた文字をそのまま記録する
+	trivia []Trivia         // CSTを作るときだけ、次のトークンの前の空白とコメントを記録する
+}
+
+// 先読みした文字と、入力での元のバイト列
+// 不正なUTF-8のバイトはU+FFFDとして読むが、入力では1バイトなので、
+// 読んだバイト数もCSTに残す文字も、runeからではなく元のバイト列から作る
+type aheadRune struct {
+	r    rune
+	text string
 }
 
 func NewLexer(input string) *Lexer {
+	return NewLexerFromReader(strings.NewReader(input))
+}
+
+// io.Readerから読むLexerを生成する
+func NewLexerFromReader(r io.Reader) *Lexer {
 	return &Lexer{
-		input:  input,
-		pos:    0,
-		line:   1,
-		column: 1,
+		src:       bufio.NewReader(r),
+		readtable: NewReadtable(),
+		pos:       0,
+		line:      1,
+		column:    1,
 	}
 }
 
 // 次のトークンを読む
 // トークンタイプがILLEGALの時はerror
 func (l *Lexer) NextToken() (Token, error) {
-	//まず空白が1文字以上あるときは、スキップする
-	l.skipWhitespace()
+	token, err := l.nextToken()
+	token.End = l.currentPos()
+	return token, err
+}
+
+func (l *Lexer) nextToken() (Token, error) {
+	//まず空白とコメントをスキップする
+	if err := l.skipWhitespaceAndComments(); err != nil {
+		return Token{Type: ILLEGAL, Value: "", Pos: l.currentPos()}, err
+	}
 
 	//もし入力が終わっているときはEOFを返す
-	if l.pos >= len(l.input) {
+	ch, ok := l.peek()
+	if !ok {
+		//読み込みエラーで終わった場合はEOFではない
+		if l.err != nil {
+			return Token{Type: ILLEGAL, Value: "", Pos: l.currentPos()}, l.err
+		}
 		return Token{Type: EOF,
 			Value: "",
 			Pos:   Position{Line: l.line, Column: l.column},
 		}, nil
 	}
 
-	ch := l.input[l.pos]
 	pos := l.currentPos()
 
 	switch ch {
@@ -73,25 +128,171 @@ func (l *Lexer) NextToken() (Token, error) {
 	case '\'': //quote
 		l.advance()
 		return Token{Type: QUOTE, Value: "'", Pos: pos}, nil
+	case '`': //quasiquote
+		l.advance()
+		return Token{Type: BACKQUOTE, Value: "`", Pos: pos}, nil
+	case ',': //unquote, unquote-splicing
+		l.advance()
+		if next, ok := l.peek(); ok && next == '@' {
+			l.advance()
+			return Token{Type: COMMA_AT, Value: ",@", Pos: pos}, nil
+		}
+		return Token{Type: COMMA, Value: ",", Pos: pos}, nil
 	case '"':
 		return l.readString()
+	case '.':
+		// 単独の.だけがドット対の区切り
+		if next, ok := l.peekAt(1); !ok || l.isTerminator(next) {
+			l.advance()
+			return Token{Type: DOT, Value: ".", Pos: pos}, nil
+		}
+	case '#':
+		// #; は次の式を1つコメントアウトする（読み飛ばすのはParserの仕事）
+		if next, ok := l.peekAt(1); ok && next == ';' {
+			l.advance()
+			l.advance()
+			return Token{Type: DATUM_COMMENT, Value: "#;", Pos: pos}, nil
+		}
+		// #\a は文字
+		if next, ok := l.peekAt(1); ok && next == '\\' {
+			return l.readCharacter()
+		}
+		// #(1 2 3) はベクタ
+		if next, ok := l.peekAt(1); ok && next == '(' {
+			l.advance()
+			l.advance()
+			return Token{Type: VECTOR, Value: "#(", Pos: pos}, nil
+		}
+		// #2A((1 2) (3 4)) は多次元配列
+		if n := l.arrayRankLength(); n > 0 {
+			var sb strings.Builder
+			for i := 0; i < n; i++ {
+				r, _ := l.peek()
+				sb.WriteRune(r)
+				l.advance()
+			}
+			return Token{Type: ARRAY, Value: sb.String(), Pos: pos}, nil
+		}
+		// リードテーブルに登録されたサブ文字
+		if sub, ok := l.readtable.matchDispatch(ch, l.peekAt); ok {
+			l.advance()
+			for range []rune(sub) {
+				l.advance()
+			}
+			return Token{Type: DISPATCH, Value: "#" + sub, Pos: pos}, nil
+		}
+		// #x1F #b1010 #o17 #36rZZ は基数つきの数値
+		if next, ok := l.peekAt(1); ok && (isRadixMarker(next) || isDigit(next)) {
+			return l.readRadixNumber()
+		}
 	}
 
-	//数値リテラルの判定、　数字または-で始まる場合は先に数字があるはず
-	if isDigit(ch) || (ch == '-' && l.pos+1 < len(l.input) && isDigit(l.input[l.pos+1])) {
-		return l.readNumber()
+	//リードテーブルに登録されたマクロ文字
+	if l.readtable.isMacroChar(ch) {
+		l.advance()
+		return Token{Type: MACRO, Value: string(ch), Pos: pos}, nil
 	}
 
-	//それ以外はシンボルとして読む
-	if isSymbolStart(ch) {
-		return l.readSymbol()
+	//数値とシンボルは、区切りまで読んでから判定する
+	//+5や1e10のように、先頭の文字だけではどちらかわからないため
+	if isDigit(ch) || ch == '.' || isSymbolStart(ch) {
+		return l.readAtom()
 	}
 	// ここまで当たらないということはエラー
+	// 続きから読めるように、読めない文字は読み飛ばしておく
+	l.advance()
 	return Token{
 		Type:  ILLEGAL,
 		Value: string(ch),
 		Pos:   pos,
-	}, fmt.Errorf("unexpected character: %c at line %d, column %d", ch, pos.Line, pos.Column)
+	}, &SyntaxError{Kind: UnexpectedCharacter, Pos: pos,
+		Found: fmt.Sprintf("'%c'", ch)}
+}
+
+// 空白とコメントをスキップする
+// ; から行末までの行コメントと、#| ... |# のブロックコメント（ネスト可）を読み飛ばす
+func (l *Lexer) skipWhitespaceAndComments() error {
+	for {
+		pos := l.currentPos()
+		l.skipWhitespace()
+		l.addTrivia(Whitespace, pos)
+
+		ch, ok := l.peek()
+		if !ok {
+			return nil
+		}
+		next, _ := l.peekAt(1)
+
+		pos = l.currentPos()
+		switch {
+		case ch == ';':
+			l.skipLineComment()
+			l.addTrivia(LineComment, pos)
+		case ch == '#' && next == '|':
+			if err := l.skipBlockComment(); err != nil {
+				return err
+			}
+			l.addTrivia(BlockComment, pos)
+		default:
+			return nil
+		}
+	}
+}
+
+// CSTを作るときに、読み飛ばした空白やコメントを記録する
+func (l *Lexer) addTrivia(kind TriviaKind, pos Position) {
+	if l.raw == nil || l.raw.Len() == 0 {
+		return
+	}
+	l.trivia = append(l.trivia, Trivia{Kind: kind, Text: l.raw.String(), Pos: pos})
+	l.raw.Reset()
+}
+
+// ; から行末までを読み飛ばす
+// 改行そのものはskipWhitespaceに任せる
+func (l *Lexer) skipLineComment() {
+	for {
+		ch, ok := l.peek()
+		if !ok || ch == '\n' {
+			return
+		}
+		l.advance()
+	}
+}
+
+// #| ... |# を読み飛ばす
+// #| |# はネストできるので深さを数える
+func (l *Lexer) skipBlockComment() error {
+	pos := l.currentPos()
+	depth := 0
+
+	for {
+		ch, ok := l.peek()
+		if !ok {
+			break
+		}
+		next, _ := l.peekAt(1)
+
+		switch {
+		case ch == '#' && next == '|':
+			depth++
+			l.advance()
+			l.advance()
+		case ch == '|' && next == '#':
+			depth--
+			l.advance()
+			l.advance()
+			if depth == 0 {
+				return nil
+			}
+		case ch == '\n':
+			l.newline()
+		default:
+			l.advance()
+		}
+	}
+
+	return &SyntaxError{Kind: UnterminatedComment, Pos: pos, Expected: "'|#'", Found: "EOF"}
 }
 
 /**
@@ -101,15 +302,16 @@ func (l *Lexer) NextToken() (Token, error) {
  * 空白が1文字もないときは何もしない
  */
 func (l *Lexer) skipWhitespace() {
-	for l.pos < len(l.input) {
-		ch := l.input[l.pos]
-		if ch == ' ' || ch == '\t' || ch == '\r' || ch == '\n' {
-			l.pos++
+	for {
+		ch, ok := l.peek()
+		if !ok {
+			break
+		}
+		if isWhitespace(ch) {
 			if ch == '\n' {
-				l.line++ // 縦に移動
-				l.column = 1
+				l.newline() // 縦に移動
 			} else {
-				l.column++ //右に移動
+				l.advance() //右に移動
 			}
 		} else {
 			// 空白以外の文字が来たときは何もしない
@@ -122,106 +324,378 @@ func (l *Lexer) currentPos() Position {
 	return Position{Line: l.line, Column: l.column}
 }
 
+// n文字先を読み進めずに覗く
+// 文字はバイトではなくrune単位
+// 入力が終わっているときはokがfalseになる
+func (l *Lexer) peekAt(n int) (rune, bool) {
+	for len(l.ahead) <= n {
+		r, size, err := l.src.ReadRune()
+		if err != nil {
+			if err != io.EOF && l.err == nil {
+				l.err = err
+			}
+			return 0, false
+		}
+		text := string(r)
+		if r == utf8.RuneError && size == 1 {
+			// 不正なバイトは、元のバイトを読み直す
+			l.src.UnreadRune()
+			b, _ := l.src.ReadByte()
+			text = string([]byte{b})
+		}
+		l.ahead = append(l.ahead, aheadRune{r: r, text: text})
+	}
+	return l.ahead[n].r, true
+}
+
+// 現在の文字を読み進めずに覗く
+func (l *Lexer) peek() (rune, bool) {
+	return l.peekAt(0)
+}
+
+// 先読みした文字を1つ取り出す
+func (l *Lexer) consume() {
+	if _, ok := l.peek(); !ok {
+		return
+	}
+	l.pos += len(l.ahead[0].text)
+	if l.raw != nil {
+		l.raw.WriteString(l.ahead[0].text)
+	}
+	l.ahead = l.ahead[1:]
+}
+
 // 1文字読み進める
 // posだけでなく、columnも進める
 // 改行はされないことに注意（なので、l.lineは進めない）
 func (l *Lexer) advance() {
-	l.pos++
+	l.consume()
 	l.column++
 }
 
+// 改行文字を1文字読み進める
+// 次の行の先頭に移動する
+func (l *Lexer) newline() {
+	l.consume()
+	l.line++
+	l.column = 1
+}
+
 func (l *Lexer) readString() (Token, error) {
 	pos := l.currentPos()
 	l.advance() // 最初の"をスキップする
 
-	start := l.pos
-	for l.pos < len(l.input) && l.input[l.pos] != '"' {
+	var sb strings.Builder
+	var escErr error // 不正なエスケープがあっても、続きから読めるように閉じる"までは読む
+	for {
+		ch, ok := l.peek()
+		//閉じる前に入力が終わったときエラー
+		if !ok {
+			if l.err != nil {
+				return Token{Type: ILLEGAL, Value: "", Pos: pos}, l.err
+			}
+			return Token{Type: ILLEGAL, Value: "", Pos: pos},
+				&SyntaxError{Kind: UnterminatedString, Pos: pos, Expected: `'"'`, Found: "EOF"}
+		}
+
+		// 閉じる"を見つけた
+		if ch == '"' {
+			break
+		}
+
+		// エスケープシーケンス
+		if ch == '\\' {
+			if err := l.readEscape(&sb); err != nil && escErr == nil {
+				escErr = err
+			}
+			continue
+		}
+
+		sb.WriteRune(ch)
 		// 改行が来てcommon lispのように複数行にわたる文字列リテラルを許す
-		if l.input[l.pos] == '\n' {
-			l.line++
-			l.column = 1
+		if ch == '\n' {
+			l.newline()
 		} else {
-			l.column++ //下位行以外の文字を読むたびに列を進める
+			l.advance() //改行以外の文字を読むたびに列を進める
 		}
-		l.pos++
 	}
 
-	//文字列が長いときエラー
-	if l.pos >= len(l.input) {
-		return Token{Type: ILLEGAL, Value: "", Pos: pos}, fmt.Errorf("unterminated string at line %d, column %d", pos.Line, pos.Column)
+	l.advance() //閉じる"をスキップ
+	if escErr != nil {
+		return Token{Type: ILLEGAL, Value: sb.String(), Pos: pos}, escErr
+	}
+	return Token{Type: STRING, Value: sb.String(), Pos: pos}, nil
+}
+
+// 文字列中の\から始まるエスケープシーケンスを読んで、sbに書き込む
+// \" \\ \n \t \uXXXX に対応する
+// それ以外の文字はCommon Lispと同じように、\の次の文字をそのまま使う
+func (l *Lexer) readEscape(sb *strings.Builder) error {
+	pos := l.currentPos()
+	l.advance() // \をスキップする
+
+	ch, ok := l.peek()
+	if !ok {
+		// 入力の終わりは、readStringが閉じていない文字列として扱う
+		return nil
 	}
 
-	//閉じていることを確認する
-	//// for loopで確認できているはずなので、省略してもよさそう
-	ch := l.input[l.pos]
-	if ch != '"' {
-		return Token{Type: ILLEGAL, Value: "", Pos: pos}, fmt.Errorf("unclosed string")
+	switch ch {
+	case 'n':
+		sb.WriteRune('\n')
+	case 't':
+		sb.WriteRune('\t')
+	case 'u':
+		l.advance() // uをスキップする
+		var code rune
+		for i := 0; i < 4; i++ {
+			h, ok := l.peek()
+			if !ok || !isHexDigit(h) {
+				found := "EOF"
+				if ok {
+					found = fmt.Sprintf("'%c'", h)
+				}
+				return &SyntaxError{Kind: InvalidEscape, Pos: pos, Expected: "4 hex digits after \\u", Found: found}
+			}
+			code = code*16 + rune(hexValue(h))
+			l.advance()
+		}
+		// サロゲートは文字にならず、書き込むとU+FFFDに化けるのでエラーにする
+		if !utf8.ValidRune(code) {
+			return &SyntaxError{Kind: InvalidEscape, Pos: pos, Expected: "a Unicode scalar value after \\u",
+				Found: fmt.Sprintf("'\\u%04X'", code)}
+		}
+		sb.WriteRune(code)
+		return nil
+	case '\n':
+		// \の直後の改行もそのまま文字列に含める
+		sb.WriteRune(ch)
+		l.newline()
+		return nil
+	default:
+		// \" と \\ もここ
+		sb.WriteRune(ch)
 	}
 
-	// 閉じる"を見つけた
-	value := l.input[start:l.pos]
-	l.advance() //閉じるをスキップ, value取得前に行うと"まで読んでしまう
-	return Token{Type: STRING, Value: value, Pos: pos}, nil
+	l.advance()
+	return nil
 }
 
-func (l *Lexer) readNumber() (Token, error) {
+// Common Lispの数値の構文
+// 整数: 123 -5 +5 10.（末尾の.は10進数の意味）
+// 分数: 1/3 -2/4
+// 浮動小数点数: 3.14 .5 -1.5 1e10 1.5d0 （指数マーカーはe s f d l）
+var (
+	integerPattern = regexp.MustCompile(`^[+-]?[0-9]+\.?$`)
+	ratioPattern   = regexp.MustCompile(`^[+-]?[0-9]+/[0-9]+$`)
+	floatPattern   = regexp.MustCompile(`^[+-]?([0-9]*\.[0-9]+([esfdlESFDL][+-]?[0-9]+)?|[0-9]+(\.[0-9]*)?[esfdlESFDL][+-]?[0-9]+)$`)
+	radixPattern   = regexp.MustCompile(`^#([xXbBoO]|[0-9]+[rR])[+-]?[0-9a-zA-Z]+(/[0-9a-zA-Z]+)?$`)
+)
+
+// 数値またはシンボルを読む
+// 区切りまで読んで、数値の構文に合えばNUMBER、そうでなければSYMBOLにする
+func (l *Lexer) readAtom() (Token, error) {
 	pos := l.currentPos()
-	start := l.pos
+	var sb strings.Builder
+	l.readWhile(&sb, l.isConstituent)
+	value := sb.String()
 
-	//マイナス記号があれば先へ
-	if l.input[l.pos] == '-' {
-		l.advance()
+	if isNumberSyntax(value) {
+		return Token{Type: NUMBER, Value: value, Pos: pos}, nil
 	}
 
-	//整数部分を読む
-	for l.pos < len(l.input) && isDigit(l.input[l.pos]) {
-		l.advance()
+	// ..のようにドットだけのトークンは読めない
+	if strings.Trim(value, ".") == "" {
+		return Token{Type: ILLEGAL, Value: value, Pos: pos},
+			&SyntaxError{Kind: InvalidToken, Pos: pos, Found: fmt.Sprintf("'%s'", value)}
 	}
 
-	//小数点があれば浮動小数点
-	if l.pos < len(l.input) && l.input[l.pos] == '.' {
-		l.advance()
-		//小数部分を読む
-		for l.pos < len(l.input) && isDigit(l.input[l.pos]) {
-			l.advance()
-		}
-	}
+	return Token{Type: SYMBOL, Value: value, Pos: pos}, nil
+}
 
-	value := l.input[start:l.pos]
+// #x1F のような基数つきの数値を読む
+// 数字が基数に合っているかはParserが数値に変換するときに確認する
+func (l *Lexer) readRadixNumber() (Token, error) {
+	pos := l.currentPos()
+	var sb strings.Builder
+	sb.WriteRune('#')
+	l.advance() // #をスキップする
+	l.readWhile(&sb, l.isConstituent)
+	value := sb.String()
+
+	if !radixPattern.MatchString(value) {
+		return Token{Type: ILLEGAL, Value: value, Pos: pos},
+			&SyntaxError{Kind: InvalidNumber, Pos: pos, Found: fmt.Sprintf("'%s'", value)}
+	}
 	return Token{Type: NUMBER, Value: value, Pos: pos}, nil
 }
 
-func (l *Lexer) readSymbol() (Token, error) {
+// #2Aのように、#のあとに数字とAが続いていれば、その文字数を返す
+// 続いていなければ0
+func (l *Lexer) arrayRankLength() int {
+	i := 1
+	for {
+		r, ok := l.peekAt(i)
+		if !ok || !isDigit(r) {
+			break
+		}
+		i++
+	}
+	if i == 1 {
+		return 0
+	}
+	if r, ok := l.peekAt(i); ok && (r == 'a' || r == 'A') {
+		return i + 1
+	}
+	return 0
+}
+
+// #\a #\Space #\あ のような文字を読む
+// #\の直後の1文字は、(や空白のような区切りの文字でもそのまま文字にする
+// 構成文字が続くときは、#\Spaceのような文字の名前として読む
+// トークンのValueは、読んだ文字1つだけの文字列
+func (l *Lexer) readCharacter() (Token, error) {
 	pos := l.currentPos()
-	start := l.pos
+	l.advance() // #をスキップする
+	l.advance() // \をスキップする
 
-	//シンボルの最初の文字を読む
-	l.advance()
+	ch, ok := l.readRune()
+	if !ok {
+		if l.err != nil {
+			return Token{Type: ILLEGAL, Value: "", Pos: pos}, l.err
+		}
+		return Token{Type: ILLEGAL, Value: "", Pos: pos},
+			&SyntaxError{Kind: UnexpectedEOF, Pos: pos, Expected: `a character after '#\'`, Found: "EOF"}
+	}
+
+	var sb strings.Builder
+	sb.WriteRune(ch)
+	if l.isConstituent(ch) {
+		l.readWhile(&sb, l.isConstituent)
+	}
+	text := sb.String()
+
+	if utf8.RuneCountInString(text) == 1 {
+		return Token{Type: CHARACTER, Value: text, Pos: pos}, nil
+	}
+	if r, ok := types.CharacterByName(text); ok {
+		return Token{Type: CHARACTER, Value: string(r), Pos: pos}, nil
+	}
+	return Token{Type: ILLEGAL, Value: text, Pos: pos},
+		&SyntaxError{Kind: InvalidCharacter, Pos: pos, Found: fmt.Sprintf("'#\\%s'", text)}
+}
+
+func isNumberSyntax(s string) bool {
+	return integerPattern.MatchString(s) ||
+		ratioPattern.MatchString(s) ||
+		floatPattern.MatchString(s)
+}
 
-	//シンボルの残りの文字を読む
-	for l.pos < len(l.input) && isSymbolChar(l.input[l.pos]) {
+// 1文字読み進めて、その文字を返す
+// 改行も正しく数える
+func (l *Lexer) readRune() (rune, bool) {
+	ch, ok := l.peek()
+	if !ok {
+		return 0, false
+	}
+	if ch == '\n' {
+		l.newline()
+	} else {
 		l.advance()
 	}
+	return ch, true
+}
 
-	value := l.input[start:l.pos]
-	return Token{Type: SYMBOL, Value: value, Pos: pos}, nil
+// 数値やシンボルの途中に使える文字
+// リードテーブルの終端マクロ文字はシンボルを区切る
+func (l *Lexer) isConstituent(ch rune) bool {
+	return isAtomChar(ch) && !l.readtable.isTerminating(ch)
+}
+
+// トークンの区切りになる文字
+func (l *Lexer) isTerminator(ch rune) bool {
+	return isDelimiter(ch) || l.readtable.isTerminating(ch)
+}
+
+// predを満たす文字が続く間読み進めて、sbに書き込む
+// 改行を含む文字には使わないこと（lineが進まない）
+func (l *Lexer) readWhile(sb *strings.Builder, pred func(rune) bool) {
+	for {
+		ch, ok := l.peek()
+		if !ok || !pred(ch) {
+			return
+		}
+		sb.WriteRune(ch)
+		l.advance()
+	}
 }
 
 // helper関数
-func isDigit(ch byte) bool {
+func isDigit(ch rune) bool {
 	return ch >= '0' && ch <= '9'
 }
 
+// 空白文字
+// 全角スペースなどUnicodeの空白も含める
+func isWhitespace(ch rune) bool {
+	return unicode.IsSpace(ch)
+}
+
+// トークンの区切りになる文字
+// 空白と、Common Lispの終端マクロ文字 " ' ( ) , ; `
+func isDelimiter(ch rune) bool {
+	switch ch {
+	case '(', ')', '"', '\'', ';', '`', ',':
+		return true
+	default:
+		return isWhitespace(ch)
+	}
+}
+
+// 数値やシンボルを構成する文字
+func isAtomChar(ch rune) bool {
+	return isSymbolChar(ch)
+}
+
+// #xの基数を表す文字
+func isRadixMarker(ch rune) bool {
+	switch ch {
+	case 'x', 'X', 'b', 'B', 'o', 'O':
+		return true
+	default:
+		return false
+	}
+}
+
+func isHexDigit(ch rune) bool {
+	return isDigit(ch) || (ch >= 'a' && ch <= 'f') || (ch >= 'A' && ch <= 'F')
+}
+
+// 16進数の1桁を数値にする
+// chはisHexDigitを満たしていること
+func hexValue(ch rune) int {
+	switch {
+	case isDigit(ch):
+		return int(ch - '0')
+	case ch >= 'a' && ch <= 'f':
+		return int(ch-'a') + 10
+	default:
+		return int(ch-'A') + 10
+	}
+}
+
 // commonlispのシンボルで使える文字を先頭にしたらsymbolとする
-func isSymbolStart(ch byte) bool {
-	return (ch >= 'a' && ch <= 'z') ||
-		(ch >= 'A' && ch <= 'Z') ||
-		ch == '+' || ch == '-' || ch == '*' || ch == '/' ||
-		ch == '=' || ch == '<' || ch == '>' || ch == '!'
+// Common Lispの構成文字（constituent）と同じく、区切り以外の表示できる文字はすべて使える
+// 日本語の識別子や ? % & $ : なども使える
+// #は非終端マクロ文字なので、先頭には使えないが途中には使える
+// |と\はCommon Lispではエスケープ文字なので、いまは使えない
+func isSymbolStart(ch rune) bool {
+	return isSymbolChar(ch) && ch != '#'
 }
 
-func isSymbolChar(ch byte) bool {
-	return isSymbolStart(ch) || isDigit(ch) ||
-		// ch == '-' // hyphenの意味だけど、isSymbolStartにこの文字の判定は含まれるのでは？
-		ch == '_'
+func isSymbolChar(ch rune) bool {
+	if isDelimiter(ch) || ch == '|' || ch == '\\' {
+		return false
+	}
+	return unicode.IsGraphic(ch)
 }
@@ -1,6 +1,9 @@
 package reader
 
-import "testing"
+import (
+	"errors"
+	"testing"
+)
 
 func TestLexer_SingleToken(t *testing.T) {
 	lexer := NewLexer("(")
@@ -34,14 +37,34 @@ func TestLexer_BasicTokens(t *testing.T) {
 		{"left paren", "(", LPAREN, "("},
 		{"right paren", ")", RPAREN, ")"},
 		{"quote", "'", QUOTE, "'"},
+		{"dot", ".", DOT, "."},
 		{"positive number", "123", NUMBER, "123"},
 		{"negative number", "-123", NUMBER, "-123"},
 		{"float", "3.14", NUMBER, "3.14"},
+		{"positive sign", "+5", NUMBER, "+5"},
+		{"leading dot", ".5", NUMBER, ".5"},
+		{"exponent", "1e10", NUMBER, "1e10"},
+		{"ratio", "1/3", NUMBER, "1/3"},
+		{"hex", "#x1F", NUMBER, "#x1F"},
+		{"binary", "#b1010", NUMBER, "#b1010"},
+		{"octal", "#o17", NUMBER, "#o17"},
+		{"one plus", "1+", SYMBOL, "1+"},
+		{"plus alone", "+", SYMBOL, "+"},
 		{"symbol", "foo", SYMBOL, "foo"},
 		{"operator plus", "+", SYMBOL, "+"},
 		{"operator minus alone", "-", SYMBOL, "-"},
 		{"string", `"hello"`, STRING, "hello"},
 		{"empty string", `""`, STRING, ""},
+		{"character", `#\a`, CHARACTER, "a"},
+		{"named character", `#\Space`, CHARACTER, " "},
+		{"named character lower case", `#\newline`, CHARACTER, "\n"},
+		{"delimiter character", `#\(`, CHARACTER, "("},
+		{"space character", `#\ `, CHARACTER, " "},
+		{"unicode character", `#\あ`, CHARACTER, "あ"},
+		{"code point character", `#\U+3042`, CHARACTER, "あ"},
+		{"vector", "#(1 2)", VECTOR, "#("},
+		{"array", "#2A((1 2))", ARRAY, "#2A"},
+		{"array lower case", "#0a5", ARRAY, "#0a"},
 	}
 
 	for _, tt := range tests {
@@ -274,6 +297,18 @@ func TestLexer_ComplexSymbols(t *testing.T) {
 		{"lambda", "lambda", "lambda"},
 		{"asterisc operator", "*", "*"},
 		{"division operator", "/", "/"},
+		{"predicate", "zerop?", "zerop?"},
+		{"percent", "%internal", "%internal"},
+		{"ampersand", "&optional", "&optional"},
+		{"dollar", "$var", "$var"},
+		{"at sign", "@foo", "@foo"},
+		{"colon", "pkg:sym", "pkg:sym"},
+		{"dot inside", "a.b", "a.b"},
+		{"sharp inside", "a#b", "a#b"},
+		{"not a number", "1.2.3", "1.2.3"},
+		{"earmuffs", "*print-base*", "*print-base*"},
+		{"japanese", "合計", "合計"},
+		{"japanese with hyphen", "値-取得", "値-取得"},
 	}
 
 	for _, tt := range tests {
@@ -297,10 +332,10 @@ func TestLexer_ComplexSymbols(t *testing.T) {
 }
 
 // 認識できない文字
-// common lispのマクロ文字に対応
+// common lispのマクロ文字やエスケープ文字に対応
 func TestLexer_UnrecoginizedCharacter(t *testing.T) {
 	tests := []string{
-		"@", "#", "$", "%",
+		"#", "|", "\\", "..",
 	}
 
 	for _, input := range tests {
@@ -332,3 +367,154 @@ func TestLexer_UnterminatedString(t *testing.T) {
 		t.Errorf("expected ILLEGAL token, got %v", token.Type)
 	}
 }
+
+// \uのサロゲートは文字にならないのでエラー
+func TestLexer_SurrogateEscape(t *testing.T) {
+	for _, input := range []string{`"\uD800"`, `"a\udfff"`} {
+		t.Run(input, func(t *testing.T) {
+			token, err := NewLexer(input).NextToken()
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) || syntaxErr.Kind != InvalidEscape {
+				t.Fatalf("expected invalid escape, got %v", err)
+			}
+			if token.Type != ILLEGAL {
+				t.Errorf("expected ILLEGAL token, got %v", token.Type)
+			}
+		})
+	}
+
+	token, err := NewLexer(`"\uD7FF\uE000"`).NextToken()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if token.Value != "\uD7FF\uE000" {
+		t.Errorf("expected %q, got %q", "\uD7FF\uE000", token.Value)
+	}
+}
+
+// コメントのテスト
+func TestLexer_Comments(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []TokenType
+	}{
+		{"line comment", "; comment\n(+ 1 2)", []TokenType{LPAREN, SYMBOL, NUMBER, NUMBER, RPAREN, EOF}},
+		{"line comment at end", "foo ; comment", []TokenType{SYMBOL, EOF}},
+		{"double semicolon", ";; comment\nfoo", []TokenType{SYMBOL, EOF}},
+		{"block comment", "#| block |# foo", []TokenType{SYMBOL, EOF}},
+		{"nested block comment", "#| outer #| inner |# still |# foo", []TokenType{SYMBOL, EOF}},
+		{"block comment in list", "(1 #| two |# 3)", []TokenType{LPAREN, NUMBER, NUMBER, RPAREN, EOF}},
+		{"datum comment", "#;foo bar", []TokenType{DATUM_COMMENT, SYMBOL, SYMBOL, EOF}},
+		{"only comment", "; nothing", []TokenType{EOF}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lexer := NewLexer(tt.input)
+			for i, want := range tt.expected {
+				token, err := lexer.NextToken()
+				if err != nil {
+					t.Fatalf("token %d: unexpected error: %v", i, err)
+				}
+				if token.Type != want {
+					t.Errorf("token %d: Type=%v, want=%v", i, token.Type, want)
+				}
+			}
+		})
+	}
+}
+
+// 複数行のコメントのあとも位置がずれないこと
+func TestLexer_CommentPosition(t *testing.T) {
+	input := "; line1\n#| a\nb\n|# (x\n  ; c\n  y)"
+	lexer := NewLexer(input)
+
+	expected := []struct {
+		typ  TokenType
+		line int
+		col  int
+	}{
+		{LPAREN, 4, 4},
+		{SYMBOL, 4, 5},
+		{SYMBOL, 6, 3},
+		{RPAREN, 6, 4},
+	}
+
+	for i, want := range expected {
+		token, err := lexer.NextToken()
+		if err != nil {
+			t.Fatalf("token %d: unexpected error: %v", i, err)
+		}
+		if token.Type != want.typ {
+			t.Errorf("token %d: Type=%v, want=%v", i, token.Type, want.typ)
+		}
+		if token.Pos.Line != want.line || token.Pos.Column != want.col {
+			t.Errorf("token %d: position=(%d, %d), want (%d, %d)",
+				i, token.Pos.Line, token.Pos.Column, want.line, want.col)
+		}
+	}
+}
+
+func TestLexer_UnterminatedBlockComment(t *testing.T) {
+	lexer := NewLexer("#| outer #| inner |# foo")
+	token, err := lexer.NextToken()
+
+	if err == nil {
+		t.Fatal("expected error for unterminated block comment")
+	}
+
+	if token.Type != ILLEGAL {
+		t.Errorf("expected ILLEGAL token, got %v", token.Type)
+	}
+}
+
+// 列はバイトではなく文字で数える
+func TestLexer_UnicodePosition(t *testing.T) {
+	input := "(あいう \"日本\" x)\n  合計"
+	lexer := NewLexer(input)
+
+	expected := []struct {
+		typ   TokenType
+		value string
+		line  int
+		col   int
+	}{
+		{LPAREN, "(", 1, 1},
+		{SYMBOL, "あいう", 1, 2},
+		{STRING, "日本", 1, 6},
+		{SYMBOL, "x", 1, 11},
+		{RPAREN, ")", 1, 12},
+		{SYMBOL, "合計", 2, 3},
+		{EOF, "", 2, 5},
+	}
+
+	for i, want := range expected {
+		token, err := lexer.NextToken()
+		if err != nil {
+			t.Fatalf("token %d: unexpected error: %v", i, err)
+		}
+		if token.Type != want.typ || token.Value != want.value {
+			t.Errorf("token %d: got (%v, %q), want (%v, %q)", i, token.Type, token.Value, want.typ, want.value)
+		}
+		if token.Pos.Line != want.line || token.Pos.Column != want.col {
+			t.Errorf("token %d: position=(%d, %d), want (%d, %d)",
+				i, token.Pos.Line, token.Pos.Column, want.line, want.col)
+		}
+	}
+}
+
+// 全角スペースも空白として扱う
+func TestLexer_UnicodeWhitespace(t *testing.T) {
+	lexer := NewLexer("a\u3000b")
+
+	for i, want := range []string{"a", "b"} {
+		token, err := lexer.NextToken()
+		if err != nil {
+			t.Fatalf("token %d: unexpected error: %v", i, err)
+		}
+		if token.Type != SYMBOL || token.Value != want {
+			t.Errorf("token %d: got (%v, %q), want SYMBOL %q", i, token.Type, token.Value, want)
+		}
+	}
+}
@@ -1,8 +1,13 @@
 package reader
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"math/big"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/koplec/gospl/internal/types"
 )
@@ -13,139 +18,313 @@ import (
 // Parserは読むだけで評価はしない
 // 例えば(+ 1 2)を読んでも3にならない
 type Parser struct {
-	lexer   *Lexer
-	current Token // 現在見ているトークン
+	lexer     *Lexer
+	readtable *Readtable     // マクロ文字の表
+	pkg       *types.Package // 修飾子のないシンボルを探すパッケージ
+	current   Token          // 現在見ているトークン
+	consumed  bool           // currentを読み終えて、次のトークンをまだ読んでいない
+	sources   *SourceMap     // 読んだ式の位置
+	lastEnd   Position       // 最後に読み終えたトークンの終わりの位置
+	lastSpan  Span           // 最後に読み終えた式の範囲
+
+	depth      int       // 読み終えた'('のうち、まだ閉じていない数
+	recovering bool      // 構文エラーのあとも読み続けるかどうか
+	errors     ErrorList // 読み続けたときに見つかった構文エラー
 }
 
 // Parserを生成する
-// 入力文字から最初のトークンを読んだ状態にする
+// トークンは必要になったときに読むので、生成しただけでは入力を読まない
+// シンボルはParserごとに作るパッケージの表にインターンする
+// 評価する環境と同じシンボルを読むには、SetPackageで環境のパッケージを渡す
 func NewParser(input string) *Parser {
-	p := &Parser{
-		lexer: NewLexer(input),
+	return newParser(NewLexer(input), "")
+}
+
+// io.Readerから読むParserを生成する
+func NewParserFromReader(r io.Reader) *Parser {
+	return newParser(NewLexerFromReader(r), "")
+}
+
+// ファイルから読むParserを生成する
+// fileは位置情報に使うファイル名
+func NewFileParser(r io.Reader, file string) *Parser {
+	return newParser(NewLexerFromReader(r), file)
+}
+
+func newParser(lexer *Lexer, file string) *Parser {
+	return &Parser{
+		lexer:     lexer,
+		readtable: lexer.readtable,
+		pkg:       types.NewRegistry().CommonLispUser,
+		consumed:  true, // 最初のトークンはまだ読んでいない
+		sources:   NewSourceMap(file),
 	}
+}
 
-	// 最初のトークンを読み込む
-	p.advance()
-	return p
+// 使うリードテーブルを切り替える
+// 式と式の間ならいつでも切り替えられる
+func (p *Parser) SetReadtable(rt *Readtable) {
+	p.readtable = rt
+	p.lexer.readtable = rt
+}
+
+// 修飾子のないシンボルを探すパッケージを切り替える
+// 修飾子のあるシンボルは、pkgを登録した表からパッケージを探す
+// 式と式の間ならいつでも切り替えられる
+func (p *Parser) SetPackage(pkg *types.Package) {
+	p.pkg = pkg
+}
+
+// 構文エラーから回復しながら読むかどうかを切り替える
+// 回復するときは、エラーのあった式や要素を読み飛ばして続きを読み、
+// 見つかったエラーはErrorsでまとめて取り出す
+func (p *Parser) SetRecovery(on bool) {
+	p.recovering = on
+}
+
+// 回復しながら読んだときに見つかった構文エラー
+func (p *Parser) Errors() ErrorList {
+	return p.errors
 }
 
 // エントリーポイント, 一つの式をパースする
+// 続けて呼ぶと次の式をパースする
+// リーダマクロの関数の中から、続きの式を読むのにも使える
 func (p *Parser) Parse() (types.Expr, error) {
 	return p.parseExpr()
 }
 
+// リーダマクロの関数の中から、続きの文字を1文字読む
+// io.RuneReaderと同じ形で、入力が終わっているときはio.EOFを返す
+// 次のトークンを読み込み済みのときは、そのトークンを指す構文エラーを返す
+func (p *Parser) ReadRune() (rune, int, error) {
+	if !p.consumed {
+		return 0, 0, p.syntaxError(UnexpectedToken, "a character")
+	}
+	start := p.lexer.pos
+	r, ok := p.lexer.readRune()
+	if !ok {
+		if p.lexer.err != nil {
+			return 0, 0, p.lexer.err
+		}
+		return 0, 0, io.EOF
+	}
+	p.lastEnd = p.lexer.currentPos()
+	return r, p.lexer.pos - start, nil
+}
+
+// リーダマクロの関数の中から、続きの文字を読み進めずに覗く
+// 入力が終わっているときはokがfalse
+func (p *Parser) PeekRune() (rune, bool) {
+	if !p.consumed {
+		return 0, false
+	}
+	return p.lexer.peek()
+}
+
+// 入力が式の途中で終わったことを表す構文エラー
+// リーダマクロの関数の中で、続きの文字がなかったときに返す
+// REPLはこのエラーのときに次の行を読んでから読み直す
+func (p *Parser) EOFError() error {
+	return &SyntaxError{
+		Kind:  UnexpectedEOF,
+		File:  p.sources.File,
+		Pos:   p.lexer.currentPos(),
+		Found: describeToken(Token{Type: EOF}),
+	}
+}
+
+// これまでに読んだ式の位置の対応表
+// Parserは読んだすべての式の位置を持ち続ける
+// 長い入力を順に読んで評価するときは、式ごとに対応表を作るReaderを使う
+func (p *Parser) SourceMap() *SourceMap {
+	return p.sources
+}
+
+// 入力に含まれるトップレベルの式をすべてパースする
+// (defun a ...) (defun b ...) のように複数の式が並ぶファイルの読み込みに使う
+// 回復しながら読むときは、読めた式と、見つかったすべての構文エラーのErrorListを返す
+func (p *Parser) ParseAll() ([]types.Expr, error) {
+	var exprs []types.Expr
+
+	for {
+		done, err := p.atEnd()
+		if err != nil {
+			if p.recover(err, 0) {
+				continue
+			}
+			return p.stop(exprs, err)
+		}
+		if done {
+			return exprs, p.errors.Err()
+		}
+
+		expr, err := p.parseExpr()
+		if err != nil {
+			if p.recover(err, 0) {
+				continue
+			}
+			return p.stop(exprs, err)
+		}
+		exprs = append(exprs, expr)
+	}
+}
+
+// もう読む式が残っていないかどうか
+// 末尾の#;コメントはここで読み飛ばしておく
+func (p *Parser) atEnd() (bool, error) {
+	if err := p.skipDatumComments(); err != nil {
+		return false, err
+	}
+	return p.current.Type == EOF, nil
+}
+
 // 1つの式をparseして、次のトークンに進む
+// 読んだ式の範囲はp.lastSpanに残し、リストならSourceMapに記録する
 func (p *Parser) parseExpr() (types.Expr, error) {
+	//#;でコメントアウトされた式を先に読み飛ばす
+	if err := p.skipDatumComments(); err != nil {
+		return nil, err
+	}
+
+	start := p.current.Pos
+	expr, err := p.parseDatum()
+	if err != nil {
+		return nil, err
+	}
+
+	span := Span{File: p.sources.File, Start: start, End: p.lastEnd}
+	if cons, ok := expr.(*types.Cons); ok {
+		p.sources.forms[cons] = span
+	}
+	p.lastSpan = span
+	return expr, nil
+}
+
+// 現在のトークンから始まる式を1つ読む
+// p.currentは読み込み済みであること
+func (p *Parser) parseDatum() (types.Expr, error) {
 	switch p.current.Type {
 	case NUMBER:
-		//トークンの値をfloat64に変換
-		value, err := strconv.ParseFloat(p.current.Value, 64)
+		//トークンの値を数値に変換
+		value, err := parseNumber(p.current.Value)
 		if err != nil {
-			return nil, fmt.Errorf("invalid number: %s", p.current.Value)
+			return nil, p.syntaxError(InvalidNumber, "")
 		}
 		//次のトークンへは進んでおく
-		if err := p.advance(); err != nil {
-			return nil, err
-		}
-		return types.Number{Value: value}, nil
+		p.advance()
+		return value, nil
 	case STRING:
 		value := p.current.Value
 
 		//次のトークンへは進んでおく
-		if err := p.advance(); err != nil {
-			return nil, err
-		}
+		p.advance()
 		return types.String{Value: value}, nil
+	case CHARACTER:
+		r, _ := utf8.DecodeRuneInString(p.current.Value)
+		p.advance()
+		return types.Character{Value: r}, nil
 	case SYMBOL:
 		value := p.current.Value
 
-		//次のトークンへは進んでおく
-		if err := p.advance(); err != nil {
-			return nil, err
-		}
-
 		//SYMBOLトークンをExprに変換するのがこの関数の目的だから
 		//BOOLEANにもここで変換が必要
 		switch value {
 		case "t":
+			p.advance()
 			return types.Boolean{Value: true}, nil
 		case "nil":
+			p.advance()
 			return &types.Nil{}, nil //common lisp風にfalseじゃなくてnil
 		default:
-			return types.Symbol{
-				Name: value,
-			}, nil
+			sym, err := p.parseSymbol(value)
+			if err != nil {
+				return nil, err
+			}
+			//次のトークンへは進んでおく
+			p.advance()
+			return sym, nil
 		}
 	case LPAREN:
 		// (が来たから　)がくるまで式を読み続ける
 		//そのためにparseList()を呼ぶ
 		return p.parseList()
-	case RPAREN:
-		// ここに到達してはダメ
-		return nil, fmt.Errorf("unexpected ')' at position %d:%d",
-			p.current.Pos.Line, p.current.Pos.Column)
-	case QUOTE:
-		// 'をスキップする
-		if err := p.advance(); err != nil {
-			return nil, err
-		}
-
-		//次の式をパース
-		expr, err := p.parseExpr()
-		if err != nil {
-			return nil, err
-		}
-
-		// (quote expr)の形に変換
-		quote := types.Symbol{Name: "quote"} //quoteシンボルを作成
-
-		//'expr = (quote expr) = (quote . (expr . nil))
-		return &types.Cons{
-			Car: quote,
-			Cdr: &types.Cons{
-				Car: expr,
-				Cdr: &types.Nil{},
-			},
-		}, nil
+	case VECTOR:
+		return p.parseVector()
+	case ARRAY:
+		return p.parseArray()
+	case DOT, RPAREN:
+		// ドットはリストの中でしか使えない
+		// ')'はここに到達してはダメ
+		return nil, p.syntaxError(UnexpectedToken, "")
+	case QUOTE, BACKQUOTE, COMMA, COMMA_AT, MACRO, DISPATCH:
+		// ' ` , ,@ やユーザ定義のマクロ文字は、リードテーブルの関数に読ませる
+		return p.parseMacro()
 	case EOF:
-		return nil, fmt.Errorf("unexpected end of input")
+		return nil, p.syntaxError(UnexpectedEOF, "")
 	default:
-		return nil, fmt.Errorf("unexpected token '%s' (type:%v) at position %d:%d",
-			p.current.Value, p.current.Type, p.current.Pos.Line, p.current.Pos.Column)
+		return nil, p.syntaxError(UnexpectedToken, "")
 	}
 
 }
 
 func (p *Parser) parseList() (types.Expr, error) {
 	//現在のトークンは'('
-	if err := p.advance(); err != nil { //(をスキップする
-		return nil, err
-	}
-
-	//空リストの場合 NIL
-	if p.current.Type == RPAREN {
-		//')'を読み飛ばす
-		if err := p.advance(); err != nil {
-			return nil, err
-		}
-		//ほかの実装では参照を返していないのに、参照を返すのは、Lispの場合、Listは実質的に参照の一覧であるから
-		return &types.Nil{}, nil
-	}
+	p.advance() //(をスキップする
+	p.depth++
+	depth := p.depth
 
 	//リストの要素を読んでいく
 	var car *types.Cons
 	var cdr *types.Cons
 
-	//')'でない限りループ
-	for p.current.Type != RPAREN && p.current.Type != EOF {
+	for {
+		//(a #;b)や(#;a)のようにコメントされた式があると、次が')'かどうか判断できないので読み飛ばす
+		//次のトークンもここで読み込まれる
+		if err := p.skipDatumComments(); err != nil {
+			if p.recover(err, depth) {
+				continue
+			}
+			return nil, err
+		}
+
+		//')'か入力の終わりまでループ
+		if p.current.Type == RPAREN || p.current.Type == EOF {
+			break
+		}
+
+		// (1 . 2)や(1 2 . 3)のドット対
+		if p.current.Type == DOT {
+			if car == nil {
+				// (. 1)のように、ドットの前に要素がない
+				err := p.syntaxError(UnexpectedToken, "an element before '.'")
+				if p.recover(err, depth) {
+					continue
+				}
+				return nil, err
+			}
+			if err := p.parseDottedTail(cdr); err != nil {
+				if p.recover(err, depth) {
+					continue
+				}
+				return nil, err
+			}
+			break
+		}
+
 		//１つの式をパース
 		expr, err := p.parseExpr()
 		if err != nil {
+			//読めなかった要素は読み飛ばして、次の要素から読み続ける
+			if p.recover(err, depth) {
+				continue
+			}
 			return nil, err
 		}
 
 		//リストは参照！
 		cons := &types.Cons{Car: expr, Cdr: &types.Nil{}}
+		p.sources.elems[cons] = p.lastSpan
 
 		if car == nil { //最初の要素
 			//ここでは、carもcdrも同じ構造cons=(expr, nil)を指し示す
@@ -167,24 +346,400 @@ func (p *Parser) parseList() (types.Expr, error) {
 
 	// ')'が最後までなかったらエラー
 	if p.current.Type != RPAREN {
-		return nil, fmt.Errorf("expected ')', got EOF")
+		return nil, p.syntaxError(UnexpectedEOF, "')'")
 	}
 
 	//')'をスキップ
-	if err := p.advance(); err != nil {
-		return nil, err
+	p.advance()
+	p.depth--
+
+	//空リストの場合 NIL
+	//ほかの実装では参照を返していないのに、参照を返すのは、Lispの場合、Listは実質的に参照の一覧であるから
+	if car == nil {
+		return &types.Nil{}, nil
 	}
 
 	// carが常に先頭を指し示すから、carを返す
 	return car, nil
 }
 
-// advanceで次のトークンを読み込む
-func (p *Parser) advance() error {
+// #(1 2 3)をベクタにする
+// 要素はリストと同じように読むが、ドット対は書けない
+func (p *Parser) parseVector() (types.Expr, error) {
+	pos := p.current.Pos
+	list, err := p.parseList()
+	if err != nil {
+		return nil, err
+	}
+
+	var elems []types.Expr
+	for current := list; ; {
+		cons, ok := current.(*types.Cons)
+		if !ok {
+			if _, ok := current.(*types.Nil); !ok {
+				return nil, &SyntaxError{Kind: UnexpectedToken, File: p.sources.File, Pos: pos,
+					Found: "'.'", Expected: "a vector without a dotted tail"}
+			}
+			break
+		}
+		elems = append(elems, cons.Car)
+		current = cons.Cdr
+	}
+	return types.NewVector(elems), nil
+}
+
+// #2A((1 2) (3 4))を多次元配列にする
+// #の後ろの数字が次元数で、次の式が中身
+func (p *Parser) parseArray() (types.Expr, error) {
+	token := p.current
+	rank, err := strconv.Atoi(token.Value[1 : len(token.Value)-1])
+	if err != nil {
+		return nil, p.syntaxError(InvalidArray, "")
+	}
+	p.advance()
+
+	contents, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	array, err := types.NewArrayFromContents(rank, contents)
+	if err != nil {
+		return nil, &SyntaxError{Kind: InvalidArray, File: p.sources.File, Pos: token.Pos,
+			Found: fmt.Sprintf("'%s%s'", token.Value, contents)}
+	}
+	return array, nil
+}
+
+// マクロ文字のトークンを読み飛ばして、リードテーブルに登録された関数に続きを読ませる
+func (p *Parser) parseMacro() (types.Expr, error) {
+	token := p.current
+	fn, ok := p.readtable.lookup(token)
+	if !ok {
+		return nil, p.syntaxError(UnknownMacro, "")
+	}
+
+	// マクロ文字をスキップする
+	// 関数はこの直後の文字から読む
+	p.advance()
+
+	expr, err := fn(p, token.Value)
+	if err != nil {
+		return nil, err
+	}
+	if expr == nil {
+		return nil, &SyntaxError{Kind: InvalidMacroResult, File: p.sources.File, Pos: token.Pos,
+			Found: describeToken(token)}
+	}
+	return expr, nil
+}
+
+// 次の式をパースし、(name expr)の形に変換する
+// 'exprを(quote expr)にするような標準のリーダマクロで使う
+func (p *Parser) readWrapped(name string) (types.Expr, error) {
+	//次の式をパース
+	expr, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	//'expr = (quote expr) = (quote . (expr . nil))
+	arg := &types.Cons{
+		Car: expr,
+		Cdr: &types.Nil{},
+	}
+	p.sources.elems[arg] = p.lastSpan
+	return &types.Cons{
+		Car: p.pkg.Registry().CommonLisp.Export(name),
+		Cdr: arg,
+	}, nil
+}
+
+// シンボルの名前を、パッケージをたどってシンボルにする
+// name         現在のパッケージから見えるシンボル（なければ作る）
+// :name        キーワード
+// pkg:name     pkgの外部シンボル
+// pkg::name    pkgのシンボル（内部シンボルでもよい、なければ作る）
+func (p *Parser) parseSymbol(text string) (types.Expr, error) {
+	pkgName, name, qualified := strings.Cut(text, ":")
+	if !qualified {
+		return p.pkg.Intern(text), nil
+	}
+
+	internal := strings.HasPrefix(name, ":")
+	if internal {
+		name = name[1:]
+	}
+	// a:b:c や pkg: や ::x は読めない
+	if name == "" || strings.Contains(name, ":") || (pkgName == "" && internal) {
+		return nil, p.syntaxError(InvalidSymbol, "")
+	}
+
+	if pkgName == "" {
+		return p.pkg.Registry().Keyword(name), nil
+	}
+
+	pkg, ok := p.pkg.Registry().FindPackage(pkgName)
+	if !ok {
+		return nil, p.syntaxError(UnknownPackage, "")
+	}
+	if internal {
+		return pkg.Intern(name), nil
+	}
+	sym, status := pkg.FindSymbol(name)
+	if status != types.SymbolExternal {
+		return nil, p.syntaxError(SymbolNotExternal, "")
+	}
+	return sym, nil
+}
+
+// ドットの後ろの式を読んで、lastのCdrにする
+// ドットの後ろには式がちょうど1つあって、そのあとは')'でないといけない
+func (p *Parser) parseDottedTail(last *types.Cons) error {
+	//'.'をスキップ
+	p.advance()
+	if err := p.skipDatumComments(); err != nil {
+		return err
+	}
+
+	// (1 .)のように、ドットの後ろに式がない
+	switch p.current.Type {
+	case EOF:
+		return p.syntaxError(UnexpectedEOF, "an expression after '.'")
+	case RPAREN, DOT:
+		return p.syntaxError(MissingExpression, "an expression after '.'")
+	}
+
+	expr, err := p.parseExpr()
+	if err != nil {
+		return err
+	}
+	last.Cdr = expr
+
+	if err := p.skipDatumComments(); err != nil {
+		return err
+	}
+
+	// (1 . 2 3)のように、ドットの後ろに式が2つ以上ある
+	if p.current.Type != RPAREN && p.current.Type != EOF {
+		return p.syntaxError(UnexpectedToken, "')' after dotted pair")
+	}
+	return nil
+}
+
+// #; の次の式を1つ読んで捨てる
+// #; #; a b のように連続する場合は、その数だけ読み飛ばす
+// 読み終えたトークンがあれば、ここで次のトークンを読み込む
+func (p *Parser) skipDatumComments() error {
+	for {
+		if err := p.fill(); err != nil {
+			return err
+		}
+		if p.current.Type != DATUM_COMMENT {
+			return nil
+		}
+
+		p.advance()
+		if err := p.fill(); err != nil {
+			return err
+		}
+		switch p.current.Type {
+		case EOF:
+			return p.syntaxError(UnexpectedEOF, "an expression after '#;'")
+		case RPAREN:
+			return p.syntaxError(MissingExpression, "an expression after '#;'")
+		}
+		if _, err := p.parseExpr(); err != nil {
+			return err
+		}
+	}
+}
+
+// 現在のトークンを読み終えたことにする
+// 次のトークンはfillで必要になったときに読む
+// 先に読んでしまうと、(set-macro-character ...)を評価する前に次の式のトークンを読んでしまうため
+func (p *Parser) advance() {
+	p.lastEnd = p.current.End
+	p.consumed = true
+}
+
+// 現在のトークンを読み終えていたら、次のトークンを読み込む
+func (p *Parser) fill() error {
+	if !p.consumed {
+		return nil
+	}
 	token, err := p.lexer.NextToken()
 	if err != nil {
+		var syntaxErr *SyntaxError
+		if errors.As(err, &syntaxErr) {
+			syntaxErr.File = p.sources.File
+		}
 		return err
 	}
 	p.current = token
+	p.consumed = false
 	return nil
 }
+
+// 現在のトークンの位置の構文エラーを作る
+// expectedは期待していたもので、特にないときは空
+func (p *Parser) syntaxError(kind SyntaxErrorKind, expected string) *SyntaxError {
+	return &SyntaxError{
+		Kind:     kind,
+		File:     p.sources.File,
+		Pos:      p.current.Pos,
+		Expected: expected,
+		Found:    describeToken(p.current),
+	}
+}
+
+// 回復しながら読んでいるなら、構文エラーを記録して、深さtargetのリストの次の要素まで読み飛ばす
+// 読み続けられるときはtrueを返す
+// 入力の途中で終わったときや、I/Oエラーのように構文エラーでないときは回復できない
+func (p *Parser) recover(err error, target int) bool {
+	if !p.recovering {
+		return false
+	}
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) || syntaxErr.Kind == UnexpectedEOF {
+		return false
+	}
+	p.errors = append(p.errors, syntaxErr)
+	return p.synchronize(target)
+}
+
+// 回復できないエラーで読むのをやめる
+// 回復しながら読んでいるときは、それまでに読めた式と、このエラーも加えたErrorListを返す
+func (p *Parser) stop(exprs []types.Expr, err error) ([]types.Expr, error) {
+	var syntaxErr *SyntaxError
+	if !p.recovering || !errors.As(err, &syntaxErr) {
+		return nil, err
+	}
+	p.errors = append(p.errors, syntaxErr)
+	return exprs, p.errors
+}
+
+// 深さtargetのリストの中まで戻るように、トークンを読み飛ばす
+// 読み飛ばしている途中で見つけた構文エラーも記録する
+func (p *Parser) synchronize(target int) bool {
+	if p.depth <= target {
+		// エラーになったトークンがまだ残っていれば、それだけ読み飛ばす
+		// ただしリストの中の')'は、そのリストを閉じるために残しておく
+		if !p.consumed && p.current.Type != EOF && (p.current.Type != RPAREN || target == 0) {
+			p.advance()
+		}
+		p.depth = target
+		return true
+	}
+
+	for p.depth > target {
+		if !p.consumed {
+			switch p.current.Type {
+			case EOF:
+				return false
+			case LPAREN, VECTOR:
+				p.depth++
+			case RPAREN:
+				p.depth--
+			}
+			p.advance()
+			continue
+		}
+		if err := p.fill(); err != nil {
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) || syntaxErr.Kind == UnterminatedString || syntaxErr.Kind == UnterminatedComment {
+				return false
+			}
+			p.errors = append(p.errors, syntaxErr)
+		}
+	}
+	return true
+}
+
+// NUMBERトークンの文字列を数値に変換する
+// 文字列はLexerが数値の構文であることを確認済み
+// 整数はint64に収まらなければBignum、分数は約分して割り切れれば整数になる
+func parseNumber(text string) (types.Expr, error) {
+	// #x1F #b1010 #o17 #36rZZ
+	if strings.HasPrefix(text, "#") {
+		return parseRadixNumber(text)
+	}
+
+	// 1/3
+	if num, den, ok := strings.Cut(text, "/"); ok {
+		return parseRatio(num, den, 10, text)
+	}
+
+	// 10. は10進数の整数
+	if integerPattern.MatchString(text) {
+		return parseInteger(strings.TrimSuffix(text, "."), 10, text)
+	}
+
+	// 1.5d0のような指数マーカーは、Goが読めるeにそろえる
+	text = strings.Map(func(r rune) rune {
+		switch r {
+		case 's', 'S', 'f', 'F', 'd', 'D', 'l', 'L':
+			return 'e'
+		}
+		return r
+	}, text)
+
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number: %s", text)
+	}
+	return types.Float{Value: value}, nil
+}
+
+// #の後ろの基数を読んで、残りをその基数の整数または分数として変換する
+func parseRadixNumber(text string) (types.Expr, error) {
+	var base int
+	var digits string
+
+	switch text[1] {
+	case 'x', 'X':
+		base, digits = 16, text[2:]
+	case 'b', 'B':
+		base, digits = 2, text[2:]
+	case 'o', 'O':
+		base, digits = 8, text[2:]
+	default:
+		// #36rZZ
+		r := strings.IndexAny(text, "rR")
+		n, err := strconv.Atoi(text[1:r])
+		if err != nil || n < 2 || n > 36 {
+			return nil, fmt.Errorf("invalid radix: %s", text)
+		}
+		base, digits = n, text[r+1:]
+	}
+
+	if num, den, ok := strings.Cut(digits, "/"); ok {
+		return parseRatio(num, den, base, text)
+	}
+	return parseInteger(digits, base, text)
+}
+
+// 整数を読む
+// textはエラーメッセージに使うトークン全体
+func parseInteger(digits string, base int, text string) (types.Expr, error) {
+	n, ok := new(big.Int).SetString(digits, base)
+	if !ok {
+		return nil, fmt.Errorf("invalid number: %s", text)
+	}
+	return types.NewInteger(n), nil
+}
+
+// 分子と分母を読んで分数にする
+func parseRatio(num, den string, base int, text string) (types.Expr, error) {
+	n, ok := new(big.Int).SetString(num, base)
+	if !ok {
+		return nil, fmt.Errorf("invalid number: %s", text)
+	}
+	d, ok := new(big.Int).SetString(den, base)
+	if !ok {
+		return nil, fmt.Errorf("invalid number: %s", text)
+	}
+	if d.Sign() == 0 {
+		return nil, fmt.Errorf("division by zero: %s", text)
+	}
+	return types.NewRational(new(big.Rat).SetFrac(n, d)), nil
+}
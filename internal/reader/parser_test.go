@@ -1,6 +1,8 @@
 package reader
 
 import (
+	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/koplec/gospl/internal/types"
@@ -9,13 +11,36 @@ import (
 func TestParseNumber(t *testing.T) {
 	tests := []struct {
 		input    string
-		expected float64
+		expected string // 数値の文字列表現
 	}{
-		{"42", 42.0},
-		{"3.14", 3.14},
-		{"-10", -10.0},
-		{"0", 0.0},
-		{"-3.14", -3.14},
+		{"42", "42"},
+		{"3.14", "3.14"},
+		{"-10", "-10"},
+		{"0", "0"},
+		{"-3.14", "-3.14"},
+		{"+5", "5"},
+		{"10.", "10"},
+		{".5", "0.5"},
+		{"-.5", "-0.5"},
+		{"1e10", "1.0e10"},
+		{"1.5e-3", "0.0015"},
+		{"2E2", "200.0"},
+		{"1.5d0", "1.5"},
+		{"3f2", "300.0"},
+		{"#x1F", "31"},
+		{"#X-ff", "-255"},
+		{"#b1010", "10"},
+		{"#o17", "15"},
+		{"#36rZZ", "1295"},
+		{"1/4", "1/4"},
+		{"-3/2", "-3/2"},
+		{"#x1/2", "1/2"},
+		{"2/4", "1/2"},
+		{"4/2", "2"},
+		{"9223372036854775807", "9223372036854775807"},
+		{"9223372036854775808", "9223372036854775808"},
+		{"-123456789012345678901234567890", "-123456789012345678901234567890"},
+		{"#xFFFFFFFFFFFFFFFFFF", "4722366482869645213695"},
 	}
 
 	for _, tt := range tests {
@@ -27,13 +52,39 @@ func TestParseNumber(t *testing.T) {
 				t.Fatalf("unexpected error:%v", err)
 			}
 
-			num, ok := expr.(types.Number)
-			if !ok {
+			if _, ok := expr.(types.Number); !ok {
 				t.Fatalf("expected Number, got %T", expr)
 			}
 
-			if num.Value != tt.expected {
-				t.Errorf("expected %v, got %v", tt.expected, num.Value)
+			if expr.String() != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, expr.String())
+			}
+		})
+	}
+}
+
+// 整数の大きさや分母によって、数値の型が決まる
+func TestParseNumberTypes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected types.Number
+	}{
+		{"42", types.Fixnum{}},
+		{"99999999999999999999", types.Bignum{}},
+		{"1/3", types.Ratio{}},
+		{"6/3", types.Fixnum{}},
+		{"1.0", types.Float{}},
+		{"1e0", types.Float{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			expr, err := NewParser(tt.input).Parse()
+			if err != nil {
+				t.Fatalf("unexpected error:%v", err)
+			}
+			if fmt.Sprintf("%T", expr) != fmt.Sprintf("%T", tt.expected) {
+				t.Errorf("expected %T, got %T", tt.expected, expr)
 			}
 		})
 	}
@@ -47,7 +98,12 @@ func TestParseString(t *testing.T) {
 		{`"hello"`, "hello"},
 		{`"hello, world"`, "hello, world"},
 		{`""`, ""},
-		//{`"multiple\nlines`, "multiple\\nlines"}, //エスケープ
+		{`"multiple\nlines"`, "multiple\nlines"},
+		{`"say \"hi\""`, `say "hi"`},
+		{`"back\\slash"`, `back\slash`},
+		{`"tab\there"`, "tab\there"},
+		{`"\u3042\u0041"`, "あA"},
+		{`"\q"`, "q"},
 	}
 
 	for _, tt := range tests {
@@ -91,7 +147,7 @@ func TestParseSymbol(t *testing.T) {
 				t.Fatalf("unexpected error:%v", err)
 			}
 
-			sym, ok := expr.(types.Symbol)
+			sym, ok := expr.(*types.Symbol)
 			if !ok {
 				t.Fatalf("expected Symbol, got %T", expr)
 			}
@@ -105,6 +161,92 @@ func TestParseSymbol(t *testing.T) {
 	}
 }
 
+func TestParseQualifiedSymbol(t *testing.T) {
+	packages := types.NewRegistry()
+	pkg, err := packages.MakePackage("parser-test", "pt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg.Export("exported")
+
+	tests := []struct {
+		input string
+		name  string
+		pkg   *types.Package
+		str   string
+	}{
+		{":test", "test", packages.KeywordPackage, ":test"},
+		{"keyword:test", "test", packages.KeywordPackage, ":test"},
+		{"foo", "foo", packages.CommonLispUser, "foo"},
+		{"parser-test:exported", "exported", pkg, "parser-test:exported"},
+		{"pt:exported", "exported", pkg, "parser-test:exported"},
+		{"parser-test::internal", "internal", pkg, "parser-test::internal"},
+		{"cl-user::foo", "foo", packages.CommonLispUser, "foo"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			p := NewParser(tt.input)
+			p.SetPackage(packages.CommonLispUser)
+			expr, err := p.Parse()
+			if err != nil {
+				t.Fatalf("unexpected error:%v", err)
+			}
+			sym, ok := expr.(*types.Symbol)
+			if !ok {
+				t.Fatalf("expected Symbol, got %T", expr)
+			}
+			if sym.Name != tt.name || sym.Package != tt.pkg {
+				t.Errorf("expected %s in %v, got %s in %v", tt.name, tt.pkg, sym.Name, sym.Package)
+			}
+			if sym.String() != tt.str {
+				t.Errorf("expected %s, got %s", tt.str, sym.String())
+			}
+		})
+	}
+
+	// 同じ名前を読むと同じシンボルになる
+	p := NewParser("pt::x parser-test::x x")
+	p.SetPackage(packages.CommonLispUser)
+	exprs, err := p.ParseAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exprs[0] != exprs[1] {
+		t.Errorf("expected pt::x and parser-test::x to be the same symbol")
+	}
+	if exprs[0] == exprs[2] {
+		t.Errorf("expected pt::x and x to be different symbols")
+	}
+}
+
+func TestParseQualifiedSymbolErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		kind  SyntaxErrorKind
+	}{
+		{"no-such-package:x", UnknownPackage},
+		{"cl-user:not-exported-symbol", SymbolNotExternal},
+		{"a:b:c", InvalidSymbol},
+		{"::x", InvalidSymbol},
+		{"cl:", InvalidSymbol},
+		{":", InvalidSymbol},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := NewParser(tt.input).Parse()
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("expected *SyntaxError, got %v", err)
+			}
+			if syntaxErr.Kind != tt.kind {
+				t.Errorf("expected %v, got %v", tt.kind, syntaxErr.Kind)
+			}
+		})
+	}
+}
+
 func TestParseBoolean(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -293,3 +435,348 @@ world"`
 		t.Errorf("expected %q, got %q", expected, str.Value)
 	}
 }
+
+func TestParseDatumComment(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"#;foo bar", "bar"},
+		{"(1 #;2 3)", "(1 3)"},
+		{"(1 #;(2 3) 4)", "(1 4)"},
+		{"(1 2 #;3)", "(1 2)"},
+		{"(#;1)", "NIL"},
+		{"(#;#;1 2 3)", "(3)"},
+		{"'#;x y", "(quote y)"},
+		{"; comment\n(+ 1 #| two |# 2) ; trailing", "(+ 1 2)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			parser := NewParser(tt.input)
+			expr, err := parser.Parse()
+
+			if err != nil {
+				t.Fatalf("unexpected error:%v", err)
+			}
+
+			if expr.String() != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, expr.String())
+			}
+		})
+	}
+}
+
+func TestParseDatumCommentErrors(t *testing.T) {
+	tests := []string{"#;", "(1 #;)"}
+
+	for _, input := range tests {
+		t.Run(input, func(t *testing.T) {
+			parser := NewParser(input)
+			expr, err := parser.Parse()
+			if err == nil {
+				t.Fatalf("expected err, got nil, expected string:%s", expr.String())
+			}
+		})
+	}
+}
+
+func TestParseAll(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{"empty", "", nil},
+		{"only comment", "; nothing here\n", nil},
+		{"single", "(+ 1 2)", []string{"(+ 1 2)"}},
+		{
+			"multiple defuns",
+			"(defun a (x) x)\n; comment\n(defun b (y) y)\n",
+			[]string{"(defun a (x) x)", "(defun b (y) y)"},
+		},
+		{"atoms", "1 foo \"bar\"", []string{"1", "foo", `"bar"`}},
+		{"trailing datum comment", "1 #;2", []string{"1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := NewParser(tt.input)
+			exprs, err := parser.ParseAll()
+			if err != nil {
+				t.Fatalf("unexpected error:%v", err)
+			}
+
+			if len(exprs) != len(tt.expected) {
+				t.Fatalf("expected %d forms, got %d", len(tt.expected), len(exprs))
+			}
+			for i, expr := range exprs {
+				if expr.String() != tt.expected[i] {
+					t.Errorf("form %d: expected %s, got %s", i, tt.expected[i], expr.String())
+				}
+			}
+		})
+	}
+}
+
+func TestParseAllErrors(t *testing.T) {
+	tests := []string{"(1 2) (3", "1 )", "#"}
+
+	for _, input := range tests {
+		t.Run(input, func(t *testing.T) {
+			parser := NewParser(input)
+			if _, err := parser.ParseAll(); err == nil {
+				t.Fatal("expected err, got nil")
+			}
+		})
+	}
+}
+
+// Parseを続けて呼ぶと次の式が読める
+func TestParseSequential(t *testing.T) {
+	parser := NewParser("a (b c)")
+
+	for _, want := range []string{"a", "(b c)"} {
+		expr, err := parser.Parse()
+		if err != nil {
+			t.Fatalf("unexpected error:%v", err)
+		}
+		if expr.String() != want {
+			t.Errorf("expected %s, got %s", want, expr.String())
+		}
+	}
+
+	if _, err := parser.Parse(); err == nil {
+		t.Error("expected error at end of input")
+	}
+}
+
+func TestParseStringEscapeErrors(t *testing.T) {
+	tests := []string{`"abc\`, `"\u12"`, `"\u12zz"`}
+
+	for _, input := range tests {
+		t.Run(input, func(t *testing.T) {
+			parser := NewParser(input)
+			expr, err := parser.Parse()
+			if err == nil {
+				t.Fatalf("expected err, got nil, expected string:%s", expr.String())
+			}
+		})
+	}
+}
+
+// 読んだ文字列を表示して、もう一度読むと同じ文字列になる
+func TestStringRoundTrip(t *testing.T) {
+	tests := []string{
+		"plain",
+		`with "quotes"`,
+		`back\slash`,
+		"line1\nline2",
+		"tab\tand\rcarriage",
+		"bell\x07",
+		"日本語",
+		"",
+	}
+
+	for _, value := range tests {
+		t.Run(value, func(t *testing.T) {
+			printed := types.String{Value: value}.String()
+
+			parser := NewParser(printed)
+			expr, err := parser.Parse()
+			if err != nil {
+				t.Fatalf("unexpected error reading %s: %v", printed, err)
+			}
+
+			str, ok := expr.(types.String)
+			if !ok {
+				t.Fatalf("expected String, got %T", expr)
+			}
+			if str.Value != value {
+				t.Errorf("expected %q, got %q (printed as %s)", value, str.Value, printed)
+			}
+		})
+	}
+}
+
+func TestParseDottedList(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(1 . 2)", "(1 . 2)"},
+		{"(1 2 . 3)", "(1 2 . 3)"},
+		{"(1 . (2 3))", "(1 2 3)"},
+		{"(1 . nil)", "(1)"},
+		{"((a . 1) (b . 2))", "((a . 1) (b . 2))"},
+		{"(a . #;c b)", "(a . b)"},
+		{"'(x . y)", "(quote (x . y))"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			parser := NewParser(tt.input)
+			expr, err := parser.Parse()
+
+			if err != nil {
+				t.Fatalf("unexpected error:%v", err)
+			}
+
+			if expr.String() != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, expr.String())
+			}
+		})
+	}
+}
+
+func TestParseDottedListErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"nothing before dot", "(. 1)"},
+		{"nothing after dot", "(1 .)"},
+		{"two after dot", "(1 . 2 3)"},
+		{"two dots", "(1 . . 2)"},
+		{"dot outside list", "."},
+		{"unclosed", "(1 . 2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := NewParser(tt.input)
+			expr, err := parser.Parse()
+			if err == nil {
+				t.Fatalf("expected err, got nil, expected string:%s", expr.String())
+			}
+		})
+	}
+}
+
+func TestParseQuasiquote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"`x", "(quasiquote x)"},
+		{"`(a ,b)", "(quasiquote (a (unquote b)))"},
+		{"`(a ,@b)", "(quasiquote (a (unquote-splicing b)))"},
+		{"`(a,b)", "(quasiquote (a (unquote b)))"},
+		{"``(a ,,b)", "(quasiquote (quasiquote (a (unquote (unquote b)))))"},
+		{"`(a . ,b)", "(quasiquote (a unquote b))"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			parser := NewParser(tt.input)
+			expr, err := parser.Parse()
+
+			if err != nil {
+				t.Fatalf("unexpected error:%v", err)
+			}
+
+			if tt.expected != expr.String() {
+				t.Errorf("expected %s, got %s", tt.expected, expr.String())
+			}
+		})
+	}
+}
+
+func TestParseNumberErrors(t *testing.T) {
+	tests := []string{"1/0", "#b102", "#x", "#1r0", "#o8"}
+
+	for _, input := range tests {
+		t.Run(input, func(t *testing.T) {
+			parser := NewParser(input)
+			expr, err := parser.Parse()
+			if err == nil {
+				t.Fatalf("expected err, got nil, expected string:%s", expr.String())
+			}
+		})
+	}
+}
+
+func TestParseCharacter(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected rune
+		printed  string
+	}{
+		{`#\a`, 'a', `#\a`},
+		{`#\A`, 'A', `#\A`},
+		{`#\Space`, ' ', `#\Space`},
+		{`#\space`, ' ', `#\Space`},
+		{`#\ `, ' ', `#\Space`},
+		{`#\Newline`, '\n', `#\Newline`},
+		{`#\Tab`, '\t', `#\Tab`},
+		{`#\あ`, 'あ', `#\あ`},
+		{`#\(`, '(', `#\(`},
+		{`#\;`, ';', `#\;`},
+		{`#\\`, '\\', `#\\`},
+		{`#\U+1F`, 0x1f, `#\U+001F`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			expr, err := NewParser(tt.input).Parse()
+			if err != nil {
+				t.Fatalf("unexpected error:%v", err)
+			}
+			ch, ok := expr.(types.Character)
+			if !ok {
+				t.Fatalf("expected Character, got %T", expr)
+			}
+			if ch.Value != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, ch.Value)
+			}
+			if ch.String() != tt.printed {
+				t.Errorf("expected %s, got %s", tt.printed, ch.String())
+			}
+		})
+	}
+
+	// リストの中では、文字の後ろの')'はリストを閉じる
+	expr, err := NewParser(`(#\a #\) #\Space)`).Parse()
+	if err != nil {
+		t.Fatalf("unexpected error:%v", err)
+	}
+	if expr.String() != `(#\a #\) #\Space)` {
+		t.Errorf("got %s", expr.String())
+	}
+}
+
+func TestParseArray(t *testing.T) {
+	tests := []struct {
+		input    string
+		dims     []int
+		expected string
+	}{
+		{"#(1 2 3)", []int{3}, "#(1 2 3)"},
+		{"#()", []int{0}, "#()"},
+		{"#(a #(b) (c d))", []int{3}, "#(a #(b) (c d))"},
+		{"#2A((1 2 3) (4 5 6))", []int{2, 3}, "#2A((1 2 3) (4 5 6))"},
+		{"#2A(#(1 2) #(3 4))", []int{2, 2}, "#2A((1 2) (3 4))"},
+		{"#1A(1 2)", []int{2}, "#(1 2)"},
+		{"#0A5", []int{}, "#0A5"},
+		{"#3A(((1) (2)))", []int{1, 2, 1}, "#3A(((1) (2)))"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			expr, err := NewParser(tt.input).Parse()
+			if err != nil {
+				t.Fatalf("unexpected error:%v", err)
+			}
+			array, ok := expr.(*types.Array)
+			if !ok {
+				t.Fatalf("expected Array, got %T", expr)
+			}
+			if fmt.Sprint(array.Dims) != fmt.Sprint(tt.dims) {
+				t.Errorf("expected dimensions %v, got %v", tt.dims, array.Dims)
+			}
+			if array.String() != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, array.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package reader
+
+import (
+	"io"
+
+	"github.com/koplec/gospl/internal/types"
+)
+
+// Readerが読んだトップレベルの式と、その範囲
+type Form struct {
+	Expr    types.Expr
+	Span    Span
+	Sources *SourceMap // この式の中のリストと要素の位置
+}
+
+// io.Readerからトップレベルの式を1つずつ読み出す
+// ファイル全体を文字列にせずに、大きなスクリプトを順に評価するために使う
+type Reader struct {
+	parser *Parser
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{
+		parser: NewParserFromReader(r),
+	}
+}
+
+// ファイルから読むReaderを生成する
+// fileは位置情報に使うファイル名
+func NewFileReader(r io.Reader, file string) *Reader {
+	return &Reader{
+		parser: NewFileParser(r, file),
+	}
+}
+
+// 使うリードテーブルを切り替える
+// 次のReadから切り替わる
+func (r *Reader) SetReadtable(rt *Readtable) {
+	r.parser.SetReadtable(rt)
+}
+
+// 修飾子のないシンボルを探すパッケージを切り替える
+// 次のReadから切り替わる
+func (r *Reader) SetPackage(pkg *types.Package) {
+	r.parser.SetPackage(pkg)
+}
+
+// 構文エラーから回復しながら読むかどうかを切り替える
+// 回復するときは、Readはエラーのあった式を読み飛ばして次の式を返す
+func (r *Reader) SetRecovery(on bool) {
+	r.parser.SetRecovery(on)
+}
+
+// 回復しながら読んだときに見つかった構文エラー
+func (r *Reader) Errors() ErrorList {
+	return r.parser.Errors()
+}
+
+// 入力の先頭から読み終えたバイト数
+// 式と式の間で呼ぶと、次の式を読み始める位置になる
+func (r *Reader) Offset() int {
+	return r.parser.lexer.pos
+}
+
+// 次のトップレベルの式を読む
+// 入力が終わったときはio.EOFを返す
+// 位置の対応表は式ごとに作り直してForm.Sourcesに入れるので、Readerは読み終えた式の位置を持ち続けない
+// 長いファイルやREPLの入力を読み続けても、対応表は大きくならない
+func (r *Reader) Read() (Form, error) {
+	r.parser.sources = NewSourceMap(r.parser.sources.File)
+	for {
+		done, err := r.parser.atEnd()
+		if err != nil {
+			if r.parser.recover(err, 0) {
+				continue
+			}
+			return Form{}, err
+		}
+		if done {
+			return Form{}, io.EOF
+		}
+
+		expr, err := r.parser.parseExpr()
+		if err != nil {
+			if r.parser.recover(err, 0) {
+				continue
+			}
+			return Form{}, err
+		}
+		return Form{Expr: expr, Span: r.parser.lastSpan, Sources: r.parser.sources}, nil
+	}
+}
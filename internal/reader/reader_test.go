@@ -0,0 +1,94 @@
+package reader
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestReader_Read(t *testing.T) {
+	input := `(defun square (x)
+  (* x x))
+
+;; comment
+(square 5)
+  'done`
+
+	expected := []struct {
+		expr string
+		line int
+		col  int
+	}{
+		{"(defun square (x) (* x x))", 1, 1},
+		{"(square 5)", 5, 1},
+		{"(quote done)", 6, 3},
+	}
+
+	// 1バイトずつしか読めないio.Readerでも動くこと
+	r := NewReader(iotest.OneByteReader(strings.NewReader(input)))
+
+	for i, want := range expected {
+		form, err := r.Read()
+		if err != nil {
+			t.Fatalf("form %d: unexpected error: %v", i, err)
+		}
+		if form.Expr.String() != want.expr {
+			t.Errorf("form %d: expected %s, got %s", i, want.expr, form.Expr.String())
+		}
+		if form.Span.Start.Line != want.line || form.Span.Start.Column != want.col {
+			t.Errorf("form %d: position=(%d, %d), want (%d, %d)",
+				i, form.Span.Start.Line, form.Span.Start.Column, want.line, want.col)
+		}
+	}
+
+	if _, err := r.Read(); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+}
+
+// 位置の対応表は式ごとに別になる
+func TestReader_SourcesPerForm(t *testing.T) {
+	r := NewFileReader(strings.NewReader("(a (b))\n(c)"), "forms.lisp")
+
+	first, err := r.Read()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second, err := r.Read()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if span, ok := first.Sources.Span(first.Expr); !ok || span.Start.Line != 1 || span.File != "forms.lisp" {
+		t.Errorf("first form: span=%+v ok=%v", span, ok)
+	}
+	if span, ok := second.Sources.Span(second.Expr); !ok || span.Start.Line != 2 {
+		t.Errorf("second form: span=%+v ok=%v", span, ok)
+	}
+	if _, ok := second.Sources.Span(first.Expr); ok {
+		t.Errorf("expected the second map not to keep the first form")
+	}
+}
+
+func TestReader_SyntaxError(t *testing.T) {
+	r := NewReader(strings.NewReader("(ok) (broken"))
+
+	if _, err := r.Read(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := r.Read(); err == nil || err == io.EOF {
+		t.Fatalf("expected syntax error, got %v", err)
+	}
+}
+
+func TestReader_ReadError(t *testing.T) {
+	readErr := errors.New("disk on fire")
+	r := NewReader(io.MultiReader(strings.NewReader("(a (b"), iotest.ErrReader(readErr)))
+
+	// 途中で読めなくなったときは、閉じ括弧がないエラーではなく読み込みエラーを返す
+	if _, err := r.Read(); !errors.Is(err, readErr) {
+		t.Errorf("expected read error, got %v", err)
+	}
+}
@@ -0,0 +1,198 @@
+package reader
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/koplec/gospl/internal/types"
+)
+
+// リーダマクロの関数
+// マクロ文字を読んだ直後に呼ばれ、続きをpから読んで式を返す
+// textは読んだマクロ文字（'、,@、#dateなど）
+// pのParseで続きの式を、ReadRuneやPeekRuneで続きの文字を読める
+type ReaderMacro func(p *Parser, text string) (types.Expr, error)
+
+// マクロ文字の登録内容
+type macroEntry struct {
+	fn             ReaderMacro
+	nonTerminating bool // trueならシンボルの途中に現れてもシンボルの一部として読む
+}
+
+// リードテーブル
+// 文字とリーダマクロの関数の対応表
+// Common Lispの*readtable*にあたる
+//
+// ディスパッチマクロ文字は#だけで、#のあとの文字（サブ文字）ごとに関数を登録する
+// Common Lispのサブ文字は1文字だが、gosplでは#date"2026-01-01"のように複数文字の名前も使える
+// サブ文字は大文字小文字を区別しない
+type Readtable struct {
+	macros   map[rune]macroEntry
+	dispatch map[rune]map[string]ReaderMacro
+	subNames map[rune][]string // dispatchのサブ文字の名前、matchDispatchで試す長い順に並べておく
+}
+
+// 標準のリードテーブルを生成する
+// ' ` , の3つと、ディスパッチマクロの#'が登録されている
+func NewReadtable() *Readtable {
+	rt := &Readtable{
+		macros: make(map[rune]macroEntry),
+		dispatch: map[rune]map[string]ReaderMacro{
+			'#': {"'": readFunction},
+		},
+		subNames: map[rune][]string{
+			'#': {"'"},
+		},
+	}
+
+	rt.macros['\''] = macroEntry{fn: readQuote}
+	rt.macros['`'] = macroEntry{fn: readBackquote}
+	rt.macros[','] = macroEntry{fn: readComma}
+	return rt
+}
+
+// リードテーブルをコピーする
+// コピーに登録しても、もとのリードテーブルは変わらない
+func (rt *Readtable) Copy() *Readtable {
+	c := &Readtable{
+		macros:   make(map[rune]macroEntry, len(rt.macros)),
+		dispatch: make(map[rune]map[string]ReaderMacro, len(rt.dispatch)),
+		subNames: make(map[rune][]string, len(rt.subNames)),
+	}
+	for ch, entry := range rt.macros {
+		c.macros[ch] = entry
+	}
+	for ch, subs := range rt.dispatch {
+		c.dispatch[ch] = make(map[string]ReaderMacro, len(subs))
+		for sub, fn := range subs {
+			c.dispatch[ch][sub] = fn
+		}
+		c.subNames[ch] = slices.Clone(rt.subNames[ch])
+	}
+	return c
+}
+
+func (rt *Readtable) String() string {
+	return "#<READTABLE>"
+}
+
+// chをマクロ文字にする
+// nonTerminatingがtrueのときは、シンボルの途中に現れたchはシンボルの一部になる
+// ( ) " ; # や空白、数字などの構文に必要な文字はマクロ文字にできない
+func (rt *Readtable) SetMacroCharacter(ch rune, fn ReaderMacro, nonTerminating bool) error {
+	switch ch {
+	case '\'', '`', ',':
+		// 標準のマクロ文字は置き換えられる
+	default:
+		if !isSymbolStart(ch) || isDigit(ch) || ch == '.' || ch == '+' || ch == '-' {
+			return fmt.Errorf("cannot make %q a macro character", ch)
+		}
+	}
+	rt.macros[ch] = macroEntry{fn: fn, nonTerminating: nonTerminating}
+	return nil
+}
+
+// dispのあとにsubが続いたときに呼ぶ関数を登録する
+// いまはdispに#しか使えない
+func (rt *Readtable) SetDispatchMacroCharacter(disp rune, sub string, fn ReaderMacro) error {
+	subs, ok := rt.dispatch[disp]
+	if !ok {
+		return fmt.Errorf("%q is not a dispatching macro character", disp)
+	}
+	if sub == "" {
+		return fmt.Errorf("dispatch sub-character must not be empty")
+	}
+	for _, r := range sub {
+		// #;や#|や#123rのような組み込みの構文と区別できなくなる文字は使えない
+		if isDigit(r) || !isSymbolChar(r) {
+			return fmt.Errorf("cannot use %q as a dispatch sub-character", sub)
+		}
+	}
+	sub = strings.ToLower(sub)
+	if _, ok := subs[sub]; !ok {
+		// 長い順に並ぶ位置に入れる
+		names := rt.subNames[disp]
+		i := sort.Search(len(names), func(i int) bool {
+			return utf8.RuneCountInString(names[i]) < utf8.RuneCountInString(sub)
+		})
+		rt.subNames[disp] = slices.Insert(names, i, sub)
+	}
+	subs[sub] = fn
+	return nil
+}
+
+// chがマクロ文字かどうか
+func (rt *Readtable) isMacroChar(ch rune) bool {
+	_, ok := rt.macros[ch]
+	return ok
+}
+
+// chがシンボルの区切りになるマクロ文字かどうか
+func (rt *Readtable) isTerminating(ch rune) bool {
+	entry, ok := rt.macros[ch]
+	return ok && !entry.nonTerminating
+}
+
+// ディスパッチマクロ文字dispの次から、登録されたサブ文字が続いているか調べる
+// 複数当てはまるときは一番長いものを返す
+// peekAt(0)がdisp自身
+func (rt *Readtable) matchDispatch(disp rune, peekAt func(int) (rune, bool)) (string, bool) {
+	// 長いものから順に試す
+	for _, name := range rt.subNames[disp] {
+		matched := true
+		for i, want := range []rune(name) {
+			r, ok := peekAt(i + 1)
+			if !ok || unicode.ToLower(r) != want {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// トークンに対応する関数を探す
+func (rt *Readtable) lookup(token Token) (ReaderMacro, bool) {
+	if token.Type == DISPATCH {
+		// token.Valueは#dateのような形
+		runes := []rune(token.Value)
+		fn, ok := rt.dispatch[runes[0]][strings.ToLower(string(runes[1:]))]
+		return fn, ok
+	}
+
+	// ,@は,の関数が読む
+	runes := []rune(token.Value)
+	entry, ok := rt.macros[runes[0]]
+	return entry.fn, ok
+}
+
+// 'expr = (quote expr)
+func readQuote(p *Parser, text string) (types.Expr, error) {
+	return p.readWrapped("quote")
+}
+
+// #'expr = (function expr)
+func readFunction(p *Parser, text string) (types.Expr, error) {
+	return p.readWrapped("function")
+}
+
+// `expr = (quasiquote expr)
+func readBackquote(p *Parser, text string) (types.Expr, error) {
+	return p.readWrapped("quasiquote")
+}
+
+// ,expr = (unquote expr)
+// ,@expr = (unquote-splicing expr)
+func readComma(p *Parser, text string) (types.Expr, error) {
+	if text == ",@" {
+		return p.readWrapped("unquote-splicing")
+	}
+	return p.readWrapped("unquote")
+}
@@ -0,0 +1,268 @@
+package reader
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/koplec/gospl/internal/types"
+)
+
+// !exprを(not expr)にするマクロ
+func readNot(p *Parser, text string) (types.Expr, error) {
+	return p.readWrapped("not")
+}
+
+// #date"2026-01-01"を(date 2026 1 1)にするマクロ
+func readDate(p *Parser, text string) (types.Expr, error) {
+	expr, err := p.Parse()
+	if err != nil {
+		return nil, err
+	}
+	str, ok := expr.(types.String)
+	if !ok {
+		return nil, errors.New("#date expects a string")
+	}
+	var elems []string
+	for _, part := range strings.Split(str.Value, "-") {
+		elems = append(elems, strings.TrimLeft(part, "0"))
+	}
+	return NewParser("(date " + strings.Join(elems, " ") + ")").Parse()
+}
+
+// [a b c]を(vector a b c)にするマクロ
+// 文字を1つずつ読んで]を探す
+func readBracket(p *Parser, text string) (types.Expr, error) {
+	var sb strings.Builder
+	for {
+		r, _, err := p.ReadRune()
+		if err != nil {
+			return nil, err
+		}
+		if r == ']' {
+			break
+		}
+		sb.WriteRune(r)
+	}
+	return NewParser("(vector " + sb.String() + ")").Parse()
+}
+
+func TestReadtable_StandardMacros(t *testing.T) {
+	parser := NewParser("'a `(b ,c ,@d) #'car #'(lambda (x) x)")
+	parser.SetReadtable(NewReadtable())
+
+	expected := []string{"(quote a)", "(quasiquote (b (unquote c) (unquote-splicing d)))",
+		"(function car)", "(function (lambda (x) x))"}
+	for _, want := range expected {
+		expr, err := parser.Parse()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if expr.String() != want {
+			t.Errorf("expected %s, got %s", want, expr.String())
+		}
+	}
+}
+
+func TestReadtable_CustomMacros(t *testing.T) {
+	rt := NewReadtable()
+	if err := rt.SetMacroCharacter('!', readNot, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.SetMacroCharacter('[', readBracket, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.SetDispatchMacroCharacter('#', "date", readDate); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"!x", "(not x)"},
+		{"(a !b c)", "(a (not b) c)"},
+		// 終端マクロ文字なのでシンボルを区切る
+		{"(foo!bar)", "(foo (not bar))"},
+		{"[1 2 3]", "(vector 1 2 3)"},
+		{"(f [1\n2])", "(f (vector 1 2))"},
+		{`#date"2026-01-01"`, "(date 2026 1 1)"},
+		{`#DATE"2026-12-31"`, "(date 2026 12 31)"},
+		// 組み込みの#構文はそのまま
+		{"#x1F", "31"},
+		{"'!x", "(quote (not x))"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			parser := NewParser(tt.input)
+			parser.SetReadtable(rt)
+			expr, err := parser.Parse()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if expr.String() != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, expr.String())
+			}
+		})
+	}
+}
+
+func TestReadtable_NonTerminating(t *testing.T) {
+	rt := NewReadtable()
+	if err := rt.SetMacroCharacter('!', readNot, true); err != nil {
+		t.Fatal(err)
+	}
+
+	parser := NewParser("(foo!bar !baz)")
+	parser.SetReadtable(rt)
+	expr, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expr.String() != "(foo!bar (not baz))" {
+		t.Errorf("got %s", expr.String())
+	}
+}
+
+// 標準の'を置き換える
+func TestReadtable_OverrideQuote(t *testing.T) {
+	rt := NewReadtable()
+	if err := rt.SetMacroCharacter('\'', readNot, false); err != nil {
+		t.Fatal(err)
+	}
+
+	parser := NewParser("'x")
+	parser.SetReadtable(rt)
+	expr, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expr.String() != "(not x)" {
+		t.Errorf("got %s", expr.String())
+	}
+}
+
+// 複数のサブ文字が当てはまるときは、登録した順によらず一番長いものを使う
+func TestReadtable_LongestDispatch(t *testing.T) {
+	rt := NewReadtable()
+	readName := func(p *Parser, text string) (types.Expr, error) {
+		return types.String{Value: text}, nil
+	}
+	for _, sub := range []string{"d", "date", "da", "DATE"} {
+		if err := rt.SetDispatchMacroCharacter('#', sub, readName); err != nil {
+			t.Fatal(err)
+		}
+	}
+	copied := rt.Copy()
+	if err := copied.SetDispatchMacroCharacter('#', "dat", readName); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.Join(rt.subNames['#'], " "); got != "date da ' d" {
+		t.Errorf("sub-characters: got %s", got)
+	}
+	tests := []struct {
+		rt       *Readtable
+		input    string
+		expected string
+	}{
+		{rt, "#date", `"#date"`},
+		{rt, "#dat", `"#da"`},
+		{rt, "#dx", `"#d"`},
+		{copied, "#dat", `"#dat"`},
+	}
+	for _, tt := range tests {
+		parser := NewParser(tt.input)
+		parser.SetReadtable(tt.rt)
+		expr, err := parser.Parse()
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.input, err)
+		}
+		if expr.String() != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, expr.String())
+		}
+	}
+}
+
+func TestReadtable_Copy(t *testing.T) {
+	original := NewReadtable()
+	copied := original.Copy()
+	if err := copied.SetMacroCharacter('!', readNot, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := copied.SetDispatchMacroCharacter('#', "date", readDate); err != nil {
+		t.Fatal(err)
+	}
+
+	// コピーへの登録はもとのリードテーブルに影響しない
+	parser := NewParser("!x")
+	parser.SetReadtable(original)
+	expr, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expr.String() != "!x" {
+		t.Errorf("original readtable changed: got %s", expr.String())
+	}
+
+	parser = NewParser(`#date"2026-01-01"`)
+	parser.SetReadtable(original)
+	if _, err := parser.Parse(); err == nil {
+		t.Error("original readtable changed: #date was readable")
+	}
+}
+
+// 式を読んだあとにリードテーブルを切り替えても、次の式から反映される
+func TestReadtable_SwitchBetweenForms(t *testing.T) {
+	parser := NewParser("!a !b")
+	first, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if first.String() != "!a" {
+		t.Errorf("got %s", first.String())
+	}
+
+	rt := NewReadtable()
+	if err := rt.SetMacroCharacter('!', readNot, false); err != nil {
+		t.Fatal(err)
+	}
+	parser.SetReadtable(rt)
+
+	second, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if second.String() != "(not b)" {
+		t.Errorf("got %s", second.String())
+	}
+}
+
+func TestReadtable_Errors(t *testing.T) {
+	rt := NewReadtable()
+
+	for _, ch := range []rune{'(', ')', '"', ';', '#', ' ', '1', '.'} {
+		if err := rt.SetMacroCharacter(ch, readNot, false); err == nil {
+			t.Errorf("expected error for macro character %q", ch)
+		}
+	}
+	for _, sub := range []string{"", "1", "|", ";"} {
+		if err := rt.SetDispatchMacroCharacter('#', sub, readDate); err == nil {
+			t.Errorf("expected error for sub-character %q", sub)
+		}
+	}
+	if err := rt.SetDispatchMacroCharacter('!', "x", readDate); err == nil {
+		t.Error("expected error for non-dispatching character")
+	}
+
+	// 関数のエラーはParseのエラーになる
+	if err := rt.SetDispatchMacroCharacter('#', "date", readDate); err != nil {
+		t.Fatal(err)
+	}
+	parser := NewParser("#date 42")
+	parser.SetReadtable(rt)
+	if _, err := parser.Parse(); err == nil {
+		t.Error("expected error from reader macro")
+	}
+}
@@ -0,0 +1,62 @@
+package reader
+
+import (
+	"fmt"
+
+	"github.com/koplec/gospl/internal/types"
+)
+
+// ソースコード上の範囲
+// Startは最初の文字の位置、Endは最後の文字の次の位置
+type Span struct {
+	File  string
+	Start Position
+	End   Position
+}
+
+// file:line:column の形で表示する
+// ファイル名がないときは line:column
+func (s Span) String() string {
+	if s.File == "" {
+		return fmt.Sprintf("%d:%d", s.Start.Line, s.Start.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d", s.File, s.Start.Line, s.Start.Column)
+}
+
+// Parserが読んだ式とソースコード上の範囲の対応表
+// types.Exprに位置を持たせると評価器やプリンタのすべてに影響するので、横に表として持つ
+//
+// Numberは値で、Symbolは同じ名前なら同じオブジェクトなので、別々の場所に書かれたものを区別できない
+// そこで、アトムの位置は「そのアトムをCarに持つConsセル」をキーにして記録する
+// 例えば(+ x 1)のxの位置は、(x 1)というセルのCarの位置
+type SourceMap struct {
+	File  string
+	forms map[*types.Cons]Span // '('から')'までのリスト全体の範囲
+	elems map[*types.Cons]Span // セルのCarの範囲
+}
+
+func NewSourceMap(file string) *SourceMap {
+	return &SourceMap{
+		File:  file,
+		forms: make(map[*types.Cons]Span),
+		elems: make(map[*types.Cons]Span),
+	}
+}
+
+// リスト全体の範囲
+// exprがParserの読んだリストでなければokがfalse
+func (m *SourceMap) Span(expr types.Expr) (Span, bool) {
+	cons, ok := expr.(*types.Cons)
+	if !ok {
+		return Span{}, false
+	}
+	span, ok := m.forms[cons]
+	return span, ok
+}
+
+// cellのCarにある要素の範囲
+// アトムの位置はこちらで調べる
+func (m *SourceMap) ElemSpan(cell *types.Cons) (Span, bool) {
+	span, ok := m.elems[cell]
+	return span, ok
+}
@@ -0,0 +1,85 @@
+package reader
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/koplec/gospl/internal/types"
+)
+
+func TestSourceMap_Spans(t *testing.T) {
+	input := "(defun f (x)\n  (+ x 10))"
+	parser := NewFileParser(strings.NewReader(input), "f.lisp")
+	expr, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sources := parser.SourceMap()
+
+	// (defun f (x) (+ x 10))
+	span, ok := sources.Span(expr)
+	if !ok {
+		t.Fatal("no span for top-level list")
+	}
+	if span.File != "f.lisp" || span.Start != (Position{1, 1}) || span.End != (Position{2, 12}) {
+		t.Errorf("top-level span = %+v", span)
+	}
+
+	// (+ x 10) は (defun f (x) (+ x 10)) の4番目の要素
+	bodyCell := nth(t, expr, 3)
+	body := bodyCell.Car
+	span, ok = sources.Span(body)
+	if !ok || span.Start != (Position{2, 3}) || span.End != (Position{2, 11}) {
+		t.Errorf("body span = %+v, %v", span, ok)
+	}
+
+	// 10 は (+ x 10) の3番目の要素
+	span, ok = sources.ElemSpan(nth(t, body, 2))
+	if !ok || span.Start != (Position{2, 8}) || span.End != (Position{2, 10}) {
+		t.Errorf("atom span = %+v, %v", span, ok)
+	}
+	if span.String() != "f.lisp:2:8" {
+		t.Errorf("span string = %s", span.String())
+	}
+
+	// 読んでいないものの位置はない
+	if _, ok := sources.Span(&types.Cons{Car: types.Fixnum{Value: 1}, Cdr: &types.Nil{}}); ok {
+		t.Error("unexpected span for a list that was not read")
+	}
+}
+
+func TestSourceMap_QuoteAndComments(t *testing.T) {
+	parser := NewParser("#| skip |# '(a #;b c)")
+	expr, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sources := parser.SourceMap()
+
+	span, ok := sources.Span(expr)
+	if !ok || span.Start != (Position{1, 12}) || span.End != (Position{1, 22}) {
+		t.Errorf("quote form span = %+v, %v", span, ok)
+	}
+
+	// (quote (a c)) の (a c) の位置
+	span, ok = sources.ElemSpan(nth(t, expr, 1))
+	if !ok || span.Start != (Position{1, 13}) || span.String() != "1:13" {
+		t.Errorf("quoted list span = %+v, %v", span, ok)
+	}
+}
+
+// listのi番目（0始まり）のセル
+func nth(t *testing.T, list types.Expr, i int) *types.Cons {
+	t.Helper()
+	for {
+		cons, ok := list.(*types.Cons)
+		if !ok {
+			t.Fatalf("list too short")
+		}
+		if i == 0 {
+			return cons
+		}
+		list = cons.Cdr
+		i--
+	}
+}
@@ -3,42 +3,96 @@ package repl
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 
 	"github.com/koplec/gospl/internal/eval"
 	"github.com/koplec/gospl/internal/reader"
+	"github.com/koplec/gospl/internal/types"
+)
+
+const (
+	prompt             = "> "
+	continuationPrompt = "... " // 式の途中で改行したときのプロンプト
 )
 
 func Start() {
-	scanner := bufio.NewScanner(os.Stdin)
+	Run(os.Stdin, os.Stdout)
+}
+
+// inから読んだ式を順に評価して、結果をoutに書く
+// 式が閉じるまでは複数行にわたって入力を受け付け、1行に複数の式があればすべて評価する
+func Run(in io.Reader, out io.Writer) {
+	scanner := bufio.NewScanner(in)
 
 	env := eval.NewGlobalEnvironment()
 
-	fmt.Println("Gospl REPL")
+	fmt.Fprintln(out, "Gospl REPL")
+
+	// まだ評価していない入力
+	// 式の途中で行が終わったときは、次の行とつなげて読み直す
+	var pending string
 
 	for {
-		fmt.Print("> ")
+		if pending == "" {
+			fmt.Fprint(out, prompt)
+		} else {
+			fmt.Fprint(out, continuationPrompt)
+		}
 
 		if !scanner.Scan() { //ctrl+Dで、EOFシグナルが送られ、falseになって、終わり。
 			break
 		}
 
-		input := scanner.Text()
+		pending += scanner.Text() + "\n"
+		pending = evalInput(pending, env, out)
+	}
+}
+
+// input中の式を順に評価する
+// 最後の式が途中で終わっていれば、その式から後ろの入力を返す
+// エラーになったときは、その行の残りの式は評価しない
+func evalInput(input string, env *eval.Environment, out io.Writer) string {
+	r := reader.NewReader(strings.NewReader(input))
+	r.SetPackage(env.Package())
+
+	for {
+		// 前の式の評価でリードテーブルが変わっているかもしれないので、式ごとに設定する
+		r.SetReadtable(env.Readtable())
+		start := r.Offset()
 
 		// Read 入力をS式に変換
-		parser := reader.NewParser(input)
-		expr, err := parser.Parse()
+		form, err := r.Read()
+		if err == io.EOF {
+			return ""
+		}
+		if reader.IsIncomplete(err) {
+			// startは読み終えたバイト数なので、inputより長くなることはないが、念のため範囲に収める
+			return input[min(start, len(input)):]
+		}
 		if err != nil {
-			fmt.Printf("Error: %v\n", err)
-			continue
+			fmt.Fprintf(out, "Error: %v\n", err)
+			return ""
 		}
 
-		result, err := eval.Eval(expr, env)
+		// エラーにこの式の中の位置を付ける
+		// REPLは入力を読み続けるので、位置情報は式を評価し終えたら捨てる
+		env.AddSourceMap(form.Sources)
+		result, err := eval.Eval(form.Expr, env)
+		env.RemoveSourceMap(form.Sources)
 		if err != nil {
-			fmt.Printf("Eval error: %v\n", err)
-			continue
+			fmt.Fprintf(out, "Eval error: %v\n", err)
+			return ""
 		}
 
-		fmt.Println(result.String())
+		// 多値は1行に1つずつ表示する
+		values := types.ValueList(result)
+		if len(values) == 0 {
+			fmt.Fprintln(out, "; No values")
+		}
+		for _, value := range values {
+			fmt.Fprintln(out, value.String())
+		}
 	}
 }
@@ -0,0 +1,70 @@
+package repl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "one form per line",
+			input:    "(+ 1 2)\n",
+			expected: "Gospl REPL\n> 3\n> ",
+		},
+		{
+			name:     "multi-line defun",
+			input:    "(defun add (a b)\n  (+ a\n     b))\n(add 1 2)\n",
+			expected: "Gospl REPL\n> ... ... add\n> 3\n> ",
+		},
+		{
+			name:     "several forms on one line",
+			input:    "1 2 (+ 1 2)\n",
+			expected: "Gospl REPL\n> 1\n2\n3\n> ",
+		},
+		{
+			name:     "form completed on the next line",
+			input:    "1 (+ 1\n2) 4\n",
+			expected: "Gospl REPL\n> 1\n... 3\n4\n> ",
+		},
+		{
+			name:     "unterminated string continues",
+			input:    "\"a\nb\"\n",
+			expected: "Gospl REPL\n> ... \"a\\nb\"\n> ",
+		},
+		{
+			name:     "multiple values one per line",
+			input:    "(values 1 2) (values)\n",
+			expected: "Gospl REPL\n> 1\n2\n; No values\n> ",
+		},
+		{
+			name:     "invalid utf-8 before a continued form",
+			input:    "'\xff\xff\xff\xff (list\n1)\n",
+			expected: "Gospl REPL\n> \uFFFD\uFFFD\uFFFD\uFFFD\n... (1)\n> ",
+		},
+		{
+			name:     "eval error with position",
+			input:    "(+ 1\n   y)\n",
+			expected: "Gospl REPL\n> ... Eval error: 2:4: undefined variable: y\n> ",
+		},
+		{
+			name:     "syntax error discards the line",
+			input:    ") 1\n2\n",
+			expected: "Gospl REPL\n> Error: 1:1: unexpected token: ')'\n> 2\n> ",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			Run(strings.NewReader(tt.input), &out)
+			if out.String() != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, out.String())
+			}
+		})
+	}
+}
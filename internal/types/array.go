@@ -0,0 +1,229 @@
+package types
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// 配列
+// 要素は行優先（最後の添字が一番速く変わる順）で1つのスライスに並べる
+// 1次元の配列がベクタで、#(1 2 3)と書く
+// 多次元の配列は#2A((1 2) (3 4))のように次元数と入れ子のリストで書く
+//
+// フィルポインタを持つベクタは、先頭からフィルポインタまでを有効な要素とみなす
+// 伸縮可能なベクタは、vector-push-extendで要素を足すと大きくなる
+type Array struct {
+	Dims        []int  // 各次元の大きさ
+	Elements    []Expr // 行優先に並べた要素
+	FillPointer int    // フィルポインタ、-1ならフィルポインタを持たない
+	Adjustable  bool   // 大きさを変えられるかどうか
+}
+
+// 配列の要素数の上限
+// これより大きな配列は、メモリを使い切らないように作らない
+const ArrayTotalSizeLimit = 1 << 24
+
+// elemsを要素に持つ単純なベクタ
+func NewVector(elems []Expr) *Array {
+	return &Array{Dims: []int{len(elems)}, Elements: elems, FillPointer: -1}
+}
+
+// 大きさdimsの配列を作り、すべての要素をinitialにする
+// 要素数がArrayTotalSizeLimitを超えるときはエラー
+func NewArray(dims []int, initial Expr) (*Array, error) {
+	size := 1
+	for _, d := range dims {
+		if d < 0 {
+			return nil, fmt.Errorf("invalid array dimension: %d", d)
+		}
+		// size*dがあふれないように、掛ける前に比べる
+		if d > ArrayTotalSizeLimit || (d > 0 && size > ArrayTotalSizeLimit/d) {
+			return nil, fmt.Errorf("array dimensions %v exceed the total size limit %d", dims, ArrayTotalSizeLimit)
+		}
+		size *= d
+	}
+	elems := make([]Expr, size)
+	for i := range elems {
+		elems[i] = initial
+	}
+	return &Array{Dims: append([]int(nil), dims...), Elements: elems, FillPointer: -1}, nil
+}
+
+// 次元数rankの入れ子になったリストまたはベクタから配列を作る
+// 大きさは入れ子の長さから決める
+// (make-array '(2 2) :initial-contents '((1 2) (3 4)))や#2A((1 2) (3 4))に使う
+func NewArrayFromContents(rank int, contents Expr) (*Array, error) {
+	dims := make([]int, rank)
+	level := contents
+	for i := range dims {
+		elems, err := sequenceElements(level)
+		if err != nil {
+			return nil, err
+		}
+		dims[i] = len(elems)
+		if len(elems) == 0 {
+			break
+		}
+		level = elems[0]
+	}
+
+	a, err := NewArray(dims, &Nil{})
+	if err != nil {
+		return nil, err
+	}
+	a.Elements = a.Elements[:0]
+	if err := a.fill(contents, 0); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// contentsを深さdepthの次元の中身として、要素を順に加える
+func (a *Array) fill(contents Expr, depth int) error {
+	if depth == len(a.Dims) {
+		a.Elements = append(a.Elements, contents)
+		return nil
+	}
+	elems, err := sequenceElements(contents)
+	if err != nil {
+		return err
+	}
+	if len(elems) != a.Dims[depth] {
+		return fmt.Errorf("array contents do not match dimensions %v: %v", a.Dims, contents)
+	}
+	for _, elem := range elems {
+		if err := a.fill(elem, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 配列の中身の1つの次元として、リストかベクタの要素を取り出す
+func sequenceElements(expr Expr) ([]Expr, error) {
+	if v, ok := expr.(*Array); ok && v.IsVector() {
+		return v.Active(), nil
+	}
+	var elems []Expr
+	current := expr
+	for {
+		switch c := current.(type) {
+		case *Nil:
+			return elems, nil
+		case *Cons:
+			elems = append(elems, c.Car)
+			current = c.Cdr
+		default:
+			return nil, fmt.Errorf("array contents must be a list or a vector: %v", expr)
+		}
+	}
+}
+
+// 次元数
+func (a *Array) Rank() int {
+	return len(a.Dims)
+}
+
+// 1次元の配列かどうか
+func (a *Array) IsVector() bool {
+	return len(a.Dims) == 1
+}
+
+// ベクタの有効な要素
+// フィルポインタがあれば、フィルポインタまで
+func (a *Array) Active() []Expr {
+	if a.FillPointer >= 0 {
+		return a.Elements[:a.FillPointer]
+	}
+	return a.Elements
+}
+
+// 添字を、行優先に並べた要素の位置にする
+// 添字の数が次元数と違うときや、範囲の外のときはエラー
+// フィルポインタは見ない
+func (a *Array) RowMajorIndex(subscripts []int) (int, error) {
+	if len(subscripts) != len(a.Dims) {
+		return 0, fmt.Errorf("wrong number of subscripts for a rank %d array: %d", len(a.Dims), len(subscripts))
+	}
+	index := 0
+	for i, s := range subscripts {
+		if s < 0 || s >= a.Dims[i] {
+			return 0, fmt.Errorf("subscript %d out of range for dimension %d of size %d", s, i, a.Dims[i])
+		}
+		index = index*a.Dims[i] + s
+	}
+	return index, nil
+}
+
+// ベクタの末尾に要素を足す
+// フィルポインタのある伸縮可能なベクタに使う
+func (a *Array) Push(elem Expr) {
+	if a.FillPointer < len(a.Elements) {
+		a.Elements[a.FillPointer] = elem
+	} else {
+		a.Elements = append(a.Elements, elem)
+		a.Dims[0] = len(a.Elements)
+	}
+	a.FillPointer++
+}
+
+// #(1 2 3) #2A((1 2) (3 4)) #0A5
+// 大きさ0の次元より後ろの次元は中身から決まらないので、(0 2)のような配列は#<ARRAY (0 2)>と書く
+// #2A()と書くと、読み戻したときに(0 0)の配列になってしまう
+func (a *Array) String() string {
+	if a.IsVector() {
+		return "#" + writeElements(a.Active())
+	}
+	if !a.readable() {
+		dims := make([]Expr, len(a.Dims))
+		for i, d := range a.Dims {
+			dims[i] = Fixnum{Value: int64(d)}
+		}
+		return "#<ARRAY " + writeElements(dims) + ">"
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "#%dA", len(a.Dims))
+	a.write(&sb, 0, 0)
+	return sb.String()
+}
+
+// #nA(...)の形で書いて、読み戻したときに同じ大きさになるかどうか
+// 大きさ0の次元より後ろは、すべて0でなければならない
+func (a *Array) readable() bool {
+	for i, d := range a.Dims {
+		if d == 0 {
+			return slices.IndexFunc(a.Dims[i:], func(d int) bool { return d != 0 }) < 0
+		}
+	}
+	return true
+}
+
+// 深さdepthの次元の、offset番目から始まる部分を書く
+func (a *Array) write(sb *strings.Builder, depth, offset int) {
+	if depth == len(a.Dims) {
+		sb.WriteString(a.Elements[offset].String())
+		return
+	}
+	stride := 1
+	for _, d := range a.Dims[depth+1:] {
+		stride *= d
+	}
+	sb.WriteByte('(')
+	for i := 0; i < a.Dims[depth]; i++ {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		a.write(sb, depth+1, offset+i*stride)
+	}
+	sb.WriteByte(')')
+}
+
+// (a b c)の形で要素を書く
+func writeElements(elems []Expr) string {
+	strs := make([]string, len(elems))
+	for i, e := range elems {
+		strs[i] = e.String()
+	}
+	return "(" + strings.Join(strs, " ") + ")"
+}
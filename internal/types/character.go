@@ -0,0 +1,78 @@
+package types
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 文字
+// Unicodeのコードポイント1つ
+// #\a #\Space #\あ のように書く
+type Character struct {
+	Value rune
+}
+
+// 名前で書く文字
+// 表示するときは最初の名前を使う
+var characterNames = []struct {
+	name string
+	char rune
+}{
+	{"Space", ' '},
+	{"Newline", '\n'},
+	{"Tab", '\t'},
+	{"Return", '\r'},
+	{"Page", '\f'},
+	{"Backspace", '\b'},
+	{"Rubout", 0x7f},
+	{"Escape", 0x1b},
+	{"Nul", 0},
+	{"Linefeed", '\n'},
+	{"Null", 0},
+	{"Esc", 0x1b},
+}
+
+// 読み直したときに同じ文字になるように表示する
+// 名前のある文字は#\Space、表示できない文字は#\U+001F
+func (c Character) String() string {
+	if name, ok := CharacterName(c.Value); ok {
+		return `#\` + name
+	}
+	if !unicode.IsGraphic(c.Value) {
+		return fmt.Sprintf(`#\U+%04X`, c.Value)
+	}
+	return `#\` + string(c.Value)
+}
+
+// 文字の名前
+// 名前のない文字ならokがfalse
+func CharacterName(r rune) (string, bool) {
+	for _, n := range characterNames {
+		if n.char == r {
+			return n.name, true
+		}
+	}
+	return "", false
+}
+
+// 名前から文字を探す
+// 大文字小文字は区別しない
+// U+3042のようなコードポイントの表記も使える
+// code-charと同じく、サロゲートのように文字にならないコードポイントは使えない
+func CharacterByName(name string) (rune, bool) {
+	for _, n := range characterNames {
+		if strings.EqualFold(n.name, name) {
+			return n.char, true
+		}
+	}
+	if len(name) > 2 && strings.EqualFold(name[:2], "U+") {
+		code, err := strconv.ParseUint(name[2:], 16, 32)
+		if err == nil && code <= unicode.MaxRune && utf8.ValidRune(rune(code)) {
+			return rune(code), true
+		}
+	}
+	return 0, false
+}
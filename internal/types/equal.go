@@ -0,0 +1,121 @@
+package types
+
+import "unicode"
+
+// Common Lispのeql
+// eqに加えて、同じ型で同じ値の数値もeqlとみなす
+// (eql 1 1)はT、(eql 1 1.0)はNIL
+func Eql(a, b Expr) bool {
+	if Eq(a, b) {
+		return true
+	}
+	switch x := a.(type) {
+	case Bignum:
+		y, ok := b.(Bignum)
+		return ok && x.Value.Cmp(y.Value) == 0
+	case Ratio:
+		y, ok := b.(Ratio)
+		return ok && x.Value.Cmp(y.Value) == 0
+	}
+	return false
+}
+
+// Common Lispのequal
+// コンスは中身をたどって比べ、それ以外はeqlで比べる
+// 文字列は値なので、同じ文字列ならeqlでもequal
+// ベクタや配列は中身を比べない（同じオブジェクトのときだけequal）
+func Equal(a, b Expr) bool {
+	for {
+		x, ok := a.(*Cons)
+		if !ok {
+			return Eql(a, b)
+		}
+		y, ok := b.(*Cons)
+		if !ok {
+			return false
+		}
+		if x == y {
+			return true
+		}
+		if !Equal(x.Car, y.Car) {
+			return false
+		}
+		a, b = x.Cdr, y.Cdr
+	}
+}
+
+// Common Lispのequalp
+// equalよりゆるく比べる
+// 数値は型が違っても値が同じならよく、文字と文字列は大文字小文字を区別しない
+// 配列は大きさが同じで要素がすべてequalpならよい
+// 構造体は型が同じでスロットの値がすべてequalpならよい
+func Equalp(a, b Expr) bool {
+	if Eq(a, b) {
+		return true
+	}
+	switch x := a.(type) {
+	case Number:
+		y, ok := b.(Number)
+		if !ok {
+			return false
+		}
+		c, ordered := Compare(x, y)
+		return ordered && c == 0
+	case Character:
+		y, ok := b.(Character)
+		return ok && unicode.ToLower(x.Value) == unicode.ToLower(y.Value)
+	case String:
+		y, ok := b.(String)
+		if !ok {
+			return false
+		}
+		xs, ys := []rune(x.Value), []rune(y.Value)
+		if len(xs) != len(ys) {
+			return false
+		}
+		for i := range xs {
+			if unicode.ToLower(xs[i]) != unicode.ToLower(ys[i]) {
+				return false
+			}
+		}
+		return true
+	case *Cons:
+		y, ok := b.(*Cons)
+		return ok && Equalp(x.Car, y.Car) && Equalp(x.Cdr, y.Cdr)
+	case *Array:
+		y, ok := b.(*Array)
+		if !ok || len(x.Dims) != len(y.Dims) {
+			return false
+		}
+		for i := range x.Dims {
+			if x.Dims[i] != y.Dims[i] {
+				return false
+			}
+		}
+		xs, ys := x.Elements, y.Elements
+		if x.IsVector() {
+			xs, ys = x.Active(), y.Active()
+		}
+		if len(xs) != len(ys) {
+			return false
+		}
+		for i := range xs {
+			if !Equalp(xs[i], ys[i]) {
+				return false
+			}
+		}
+		return true
+	case *Structure:
+		y, ok := b.(*Structure)
+		if !ok || x.Type != y.Type {
+			return false
+		}
+		for i := range x.Values {
+			if !Equalp(x.Values[i], y.Values[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
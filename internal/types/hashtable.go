@@ -0,0 +1,260 @@
+package types
+
+import (
+	"container/list"
+	"fmt"
+	"hash/maphash"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// ハッシュテーブルのキーの比べ方
+type HashTest int
+
+const (
+	TestEq HashTest = iota
+	TestEql
+	TestEqual
+	TestEqualp
+)
+
+// eq eql equal equalp
+func (t HashTest) String() string {
+	switch t {
+	case TestEq:
+		return "eq"
+	case TestEql:
+		return "eql"
+	case TestEqual:
+		return "equal"
+	default:
+		return "equalp"
+	}
+}
+
+// 名前から比べ方を探す
+func HashTestByName(name string) (HashTest, bool) {
+	for _, t := range []HashTest{TestEq, TestEql, TestEqual, TestEqualp} {
+		if t.String() == name {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
+// ハッシュテーブル
+// キーは比べ方に合わせてハッシュ値を計算する
+// equalやequalpのテーブルでは、リストや文字列も中身でハッシュ値を計算する
+// 要素は入れた順に覚えておき、maphashではその順にたどる
+type HashTable struct {
+	Test    HashTest
+	seed    maphash.Seed
+	buckets map[uint64][]*list.Element // ハッシュ値から要素を引く表
+	entries *list.List                 // 入れた順の要素、値は*hashEntry
+}
+
+type hashEntry struct {
+	key     Expr
+	value   Expr
+	removed bool // 取り除かれたかどうか、maphashの途中で取り除かれたものを飛ばすのに使う
+}
+
+func NewHashTable(test HashTest) *HashTable {
+	return &HashTable{
+		Test:    test,
+		seed:    maphash.MakeSeed(),
+		buckets: make(map[uint64][]*list.Element),
+		entries: list.New(),
+	}
+}
+
+// 要素の数
+func (h *HashTable) Count() int {
+	return h.entries.Len()
+}
+
+// keyの値を探す
+func (h *HashTable) Get(key Expr) (Expr, bool) {
+	if elem := h.find(h.hash(key), key); elem != nil {
+		return elem.Value.(*hashEntry).value, true
+	}
+	return nil, false
+}
+
+// keyの値をvalueにする
+func (h *HashTable) Put(key, value Expr) {
+	sum := h.hash(key)
+	if elem := h.find(sum, key); elem != nil {
+		elem.Value.(*hashEntry).value = value
+		return
+	}
+	elem := h.entries.PushBack(&hashEntry{key: key, value: value})
+	h.buckets[sum] = append(h.buckets[sum], elem)
+}
+
+// keyを取り除く
+// 取り除いたときはtrue
+func (h *HashTable) Remove(key Expr) bool {
+	sum := h.hash(key)
+	bucket := h.buckets[sum]
+	for i, elem := range bucket {
+		entry := elem.Value.(*hashEntry)
+		if h.same(entry.key, key) {
+			entry.removed = true
+			h.entries.Remove(elem)
+			if len(bucket) == 1 {
+				delete(h.buckets, sum)
+			} else {
+				h.buckets[sum] = append(bucket[:i:i], bucket[i+1:]...)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// すべての要素を取り除く
+func (h *HashTable) Clear() {
+	for elem := h.entries.Front(); elem != nil; elem = elem.Next() {
+		elem.Value.(*hashEntry).removed = true
+	}
+	h.buckets = make(map[uint64][]*list.Element)
+	h.entries.Init()
+}
+
+// 入れた順に要素をたどる
+// fnの中で要素を取り除いたり値を変えたりしてもよい
+// 取り除かれた要素はたどらず、fnの中で足した要素はたどらない
+// fnがエラーを返したらそこでやめる
+func (h *HashTable) Range(fn func(key, value Expr) error) error {
+	var snapshot []*hashEntry
+	for elem := h.entries.Front(); elem != nil; elem = elem.Next() {
+		snapshot = append(snapshot, elem.Value.(*hashEntry))
+	}
+	for _, entry := range snapshot {
+		if entry.removed {
+			continue
+		}
+		if err := fn(entry.key, entry.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *HashTable) String() string {
+	return fmt.Sprintf("#<HASH-TABLE :test %s :count %d>", h.Test, h.Count())
+}
+
+// ハッシュ値がsumの要素からkeyを探す
+func (h *HashTable) find(sum uint64, key Expr) *list.Element {
+	for _, elem := range h.buckets[sum] {
+		if h.same(elem.Value.(*hashEntry).key, key) {
+			return elem
+		}
+	}
+	return nil
+}
+
+// テーブルの比べ方で同じキーかどうか
+func (h *HashTable) same(a, b Expr) bool {
+	switch h.Test {
+	case TestEq:
+		return Eq(a, b)
+	case TestEql:
+		return Eql(a, b)
+	case TestEqual:
+		return Equal(a, b)
+	default:
+		return Equalp(a, b)
+	}
+}
+
+// 中身をたどってハッシュ値を計算する深さ
+// リストの要素や配列、構造体の中身に入るたびに1つ減らす
+// 循環した構造でも止まるように、これより深いところはハッシュ値に含めない
+const hashDepth = 4
+
+// ハッシュ値に含めるリストの要素の数
+// リストの並び（cdr）は深さを減らさずにたどるので、循環したリストでも止まるように長さで区切る
+const hashLength = 32
+
+// キーのハッシュ値
+// 比べ方で同じになるキーは、必ず同じハッシュ値になる
+func (h *HashTable) hash(key Expr) uint64 {
+	var mh maphash.Hash
+	mh.SetSeed(h.seed)
+	h.writeHash(&mh, key, hashDepth)
+	return mh.Sum64()
+}
+
+func (h *HashTable) writeHash(mh *maphash.Hash, key Expr, depth int) {
+	// (equalp 1 1.0)なので、型によらず値で計算する
+	// equalpな数値は値が正確に等しいので、浮動小数点数に直しても同じ値になる
+	if n, ok := key.(Number); ok && h.Test == TestEqualp {
+		maphash.WriteComparable(mh, ToFloat(n))
+		return
+	}
+
+	// 型ごとに区別する
+	mh.WriteString(reflect.TypeOf(key).String())
+	if depth == 0 {
+		return
+	}
+
+	if h.Test == TestEqualp {
+		switch k := key.(type) {
+		case Character:
+			maphash.WriteComparable(mh, unicode.ToLower(k.Value))
+			return
+		case String:
+			mh.WriteString(strings.ToLower(k.Value))
+			return
+		case *Array:
+			for _, elem := range k.Active() {
+				h.writeHash(mh, elem, depth-1)
+			}
+			return
+		case *Structure:
+			maphash.WriteComparable(mh, k.Type)
+			for _, value := range k.Values {
+				h.writeHash(mh, value, depth-1)
+			}
+			return
+		}
+	}
+
+	switch k := key.(type) {
+	case Bignum:
+		mh.Write(k.Value.Bytes())
+		maphash.WriteComparable(mh, k.Value.Sign())
+	case Ratio:
+		mh.WriteString(k.Value.String())
+	case *Nil:
+		// NILは毎回別のポインタで作られるので、型だけで計算する
+	case *Cons:
+		if h.Test == TestEq || h.Test == TestEql {
+			maphash.WriteComparable(mh, k)
+			return
+		}
+		// (a b c 1)と(a b c 2)を区別できるように、並びは深さではなく長さで区切る
+		var rest Expr = k
+		for i := 0; i < hashLength; i++ {
+			cons, ok := rest.(*Cons)
+			if !ok {
+				// 末尾のNILか、ドット対のcdr
+				h.writeHash(mh, rest, depth-1)
+				break
+			}
+			h.writeHash(mh, cons.Car, depth-1)
+			rest = cons.Cdr
+		}
+	default:
+		// 関数はポインタなので同じ関数なら同じハッシュ値になる
+		// 多値のように比べられない値は、型だけで計算する
+		if reflect.TypeOf(key).Comparable() {
+			maphash.WriteComparable(mh, key)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package types
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// 数値
+// Common Lispと同じように、整数（fixnumとbignum）、分数、浮動小数点数の階層を持つ
+// 整数と分数は誤差なく計算し、浮動小数点数が混ざったときだけ浮動小数点数で計算する
+type Number interface {
+	Expr
+	isNumber()
+}
+
+// int64に収まる整数
+type Fixnum struct {
+	Value int64
+}
+
+// int64に収まらない整数
+// int64に収まる値は常にFixnumにするので、Bignumどうしの値が同じならFixnumにはならない
+// Valueは共有されるので書き換えないこと
+type Bignum struct {
+	Value *big.Int
+}
+
+// 分数
+// 常に既約分数で、分母は2以上（分母が1になるときは整数にする）
+// Valueは共有されるので書き換えないこと
+type Ratio struct {
+	Value *big.Rat
+}
+
+// 浮動小数点数
+type Float struct {
+	Value float64
+}
+
+func (Fixnum) isNumber() {}
+func (Bignum) isNumber() {}
+func (Ratio) isNumber()  {}
+func (Float) isNumber()  {}
+
+func (n Fixnum) String() string {
+	return strconv.FormatInt(n.Value, 10)
+}
+
+func (n Bignum) String() string {
+	return n.Value.String()
+}
+
+func (n Ratio) String() string {
+	return n.Value.RatString()
+}
+
+// Common Lispと同じく、整数と区別できるように必ず小数点を付ける
+// 1.0e-3以上1.0e7未満はそのまま、それ以外は指数表記にする
+// 1.0 1.5 1234567.0 1.0e10 1.5e-7
+func (n Float) String() string {
+	f := n.Value
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+
+	if abs := math.Abs(f); abs == 0 || (abs >= 1e-3 && abs < 1e7) {
+		s := strconv.FormatFloat(f, 'f', -1, 64)
+		if !strings.Contains(s, ".") {
+			s += ".0"
+		}
+		return s
+	}
+
+	// 1e+10 -> 1.0e10
+	mantissa, exponent, _ := strings.Cut(strconv.FormatFloat(f, 'e', -1, 64), "e")
+	if !strings.Contains(mantissa, ".") {
+		mantissa += ".0"
+	}
+	return mantissa + "e" + strings.TrimPrefix(exponent, "+")
+}
+
+// 整数をNumberにする
+// int64に収まればFixnum、収まらなければBignum
+func NewInteger(n *big.Int) Number {
+	if n.IsInt64() {
+		return Fixnum{Value: n.Int64()}
+	}
+	return Bignum{Value: n}
+}
+
+// 有理数をNumberにする
+// 分母が1なら整数、そうでなければRatio
+func NewRational(r *big.Rat) Number {
+	if r.IsInt() {
+		return NewInteger(new(big.Int).Set(r.Num()))
+	}
+	return Ratio{Value: r}
+}
+
+// 分数 num/den を数値にする
+// 約分して、割り切れるときは整数になる
+func NewRatio(num, den int64) (Number, error) {
+	if den == 0 {
+		return nil, fmt.Errorf("division by zero: %d/%d", num, den)
+	}
+	return NewRational(big.NewRat(num, den)), nil
+}
+
+// 整数かどうか
+func IsInteger(n Number) bool {
+	switch n.(type) {
+	case Fixnum, Bignum:
+		return true
+	default:
+		return false
+	}
+}
+
+// 数値の型の順位
+// 演算では順位の高いほうの型にそろえる（整数 < 分数 < 浮動小数点数）
+func rank(n Number) int {
+	switch n.(type) {
+	case Fixnum, Bignum:
+		return 0
+	case Ratio:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// 浮動小数点数が混ざっているかどうか
+func isFloatContagion(a, b Number) bool {
+	return rank(a) == 2 || rank(b) == 2
+}
+
+// 有理数としての値
+// Floatには使わないこと
+func toRat(n Number) *big.Rat {
+	switch v := n.(type) {
+	case Fixnum:
+		return new(big.Rat).SetInt64(v.Value)
+	case Bignum:
+		return new(big.Rat).SetInt(v.Value)
+	case Ratio:
+		return v.Value
+	}
+	panic(fmt.Sprintf("toRat: not a rational: %v", n))
+}
+
+// 浮動小数点数としての値
+func ToFloat(n Number) float64 {
+	switch v := n.(type) {
+	case Fixnum:
+		return float64(v.Value)
+	case Bignum:
+		f, _ := new(big.Float).SetInt(v.Value).Float64()
+		return f
+	case Ratio:
+		f, _ := v.Value.Float64()
+		return f
+	case Float:
+		return v.Value
+	}
+	panic(fmt.Sprintf("ToFloat: not a number: %v", n))
+}
+
+// a + b
+func Add(a, b Number) Number {
+	if x, ok := a.(Fixnum); ok {
+		if y, ok := b.(Fixnum); ok {
+			sum := x.Value + y.Value
+			// 符号が同じ数を足して符号が変わったらあふれている
+			if (x.Value >= 0) == (y.Value >= 0) && (sum >= 0) != (x.Value >= 0) {
+				return NewInteger(new(big.Int).Add(big.NewInt(x.Value), big.NewInt(y.Value)))
+			}
+			return Fixnum{Value: sum}
+		}
+	}
+	if isFloatContagion(a, b) {
+		return Float{Value: ToFloat(a) + ToFloat(b)}
+	}
+	return NewRational(new(big.Rat).Add(toRat(a), toRat(b)))
+}
+
+// a - b
+func Sub(a, b Number) Number {
+	return Add(a, Negate(b))
+}
+
+// -a
+func Negate(a Number) Number {
+	switch v := a.(type) {
+	case Fixnum:
+		if v.Value == math.MinInt64 {
+			return NewInteger(new(big.Int).Neg(big.NewInt(v.Value)))
+		}
+		return Fixnum{Value: -v.Value}
+	case Bignum:
+		return NewInteger(new(big.Int).Neg(v.Value))
+	case Ratio:
+		return NewRational(new(big.Rat).Neg(v.Value))
+	default:
+		return Float{Value: -ToFloat(a)}
+	}
+}
+
+// a * b
+func Mul(a, b Number) Number {
+	if x, ok := a.(Fixnum); ok {
+		if y, ok := b.(Fixnum); ok {
+			product := x.Value * y.Value
+			// 割り戻して元に戻らなければあふれている
+			overflow := x.Value != 0 && (product/x.Value != y.Value ||
+				(x.Value == -1 && y.Value == math.MinInt64))
+			if !overflow {
+				return Fixnum{Value: product}
+			}
+			return NewInteger(new(big.Int).Mul(big.NewInt(x.Value), big.NewInt(y.Value)))
+		}
+	}
+	if isFloatContagion(a, b) {
+		return Float{Value: ToFloat(a) * ToFloat(b)}
+	}
+	return NewRational(new(big.Rat).Mul(toRat(a), toRat(b)))
+}
+
+// a / b
+// 整数どうしで割り切れないときは分数になる (/ 1 3) -> 1/3
+func Div(a, b Number) (Number, error) {
+	if IsZero(b) {
+		return nil, fmt.Errorf("division by zero")
+	}
+	if isFloatContagion(a, b) {
+		return Float{Value: ToFloat(a) / ToFloat(b)}, nil
+	}
+	return NewRational(new(big.Rat).Quo(toRat(a), toRat(b))), nil
+}
+
+// aとbを比べる
+// a < bなら負、a == bなら0、a > bなら正
+// どちらかがNaNなら大小が決まらないので、okがfalseになる
+// 有理数と浮動小数点数は、浮動小数点数を有理数に直して正確に比べる（CLHS 12.1.4.1）
+// (= 9007199254740993 9007199254740992.0)はNIL
+func Compare(a, b Number) (int, bool) {
+	if x, ok := a.(Fixnum); ok {
+		if y, ok := b.(Fixnum); ok {
+			return cmp.Compare(x.Value, y.Value), true
+		}
+	}
+	x, xFloat := a.(Float)
+	y, yFloat := b.(Float)
+	switch {
+	case xFloat && yFloat:
+		if math.IsNaN(x.Value) || math.IsNaN(y.Value) {
+			return 0, false
+		}
+		return cmp.Compare(x.Value, y.Value), true
+	case xFloat:
+		return compareFloatRational(x.Value, b)
+	case yFloat:
+		c, ok := compareFloatRational(y.Value, a)
+		return -c, ok
+	}
+	return toRat(a).Cmp(toRat(b)), true
+}
+
+// 浮動小数点数fと有理数rを比べる
+// 無限大はどの有理数よりも大きい（小さい）
+func compareFloatRational(f float64, r Number) (int, bool) {
+	switch {
+	case math.IsNaN(f):
+		return 0, false
+	case math.IsInf(f, 1):
+		return 1, true
+	case math.IsInf(f, -1):
+		return -1, true
+	}
+	return new(big.Rat).SetFloat64(f).Cmp(toRat(r)), true
+}
+
+// 0かどうか
+func IsZero(n Number) bool {
+	switch v := n.(type) {
+	case Fixnum:
+		return v.Value == 0
+	case Float:
+		return v.Value == 0
+	default:
+		// BignumとRatioは0にならない
+		return false
+	}
+}
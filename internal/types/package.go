@@ -0,0 +1,175 @@
+package types
+
+import (
+	"fmt"
+)
+
+// パッケージ
+// シンボルの名前空間で、名前からシンボルを探すための表を持つ
+// common-lisp:carのようなパッケージ修飾子は、パッケージを名前で探してから、その中のシンボルを探す
+type Package struct {
+	Name      string
+	Nicknames []string
+	registry  *Registry          // このパッケージを登録した表
+	use       []*Package         // 外部シンボルを継承するパッケージ
+	symbols   map[string]*Symbol // このパッケージにあるシンボル
+	external  map[string]bool    // 外部シンボルの名前
+}
+
+// シンボルがパッケージからどう見えるか
+type SymbolStatus int
+
+const (
+	SymbolNotFound  SymbolStatus = iota // 見つからない
+	SymbolInternal                      // このパッケージの内部シンボル
+	SymbolExternal                      // このパッケージの外部シンボル
+	SymbolInherited                     // useしているパッケージの外部シンボル
+)
+
+// パッケージの表
+// 名前とニックネームからパッケージを引く
+// 表ごとに別のcommon-lispやkeywordパッケージを持つので、
+// ある表で作ったパッケージやシンボルは、別の表からは見えない
+// defstructで定義した構造体の型も、名前のシンボルと同じ表に持つ
+type Registry struct {
+	packages       map[string]*Package
+	structTypes    map[*Symbol]*StructType // 名前から構造体の型を引く表
+	CommonLisp     *Package
+	CommonLispUser *Package
+	KeywordPackage *Package
+}
+
+// 組み込みのパッケージだけがある表を作る
+func NewRegistry() *Registry {
+	r := &Registry{
+		packages:    make(map[string]*Package),
+		structTypes: make(map[*Symbol]*StructType),
+	}
+	r.CommonLisp = r.mustMakePackage("common-lisp", "cl")
+	r.CommonLispUser = r.mustMakePackage("common-lisp-user", "cl-user")
+	r.KeywordPackage = r.mustMakePackage("keyword")
+	r.CommonLispUser.Use(r.CommonLisp)
+	return r
+}
+
+// パッケージを作って登録する
+// 名前かニックネームがすでに使われているときはエラー
+func (r *Registry) MakePackage(name string, nicknames ...string) (*Package, error) {
+	for _, n := range append([]string{name}, nicknames...) {
+		if _, ok := r.packages[n]; ok {
+			return nil, fmt.Errorf("package %s already exists", n)
+		}
+	}
+
+	p := &Package{
+		Name:      name,
+		Nicknames: nicknames,
+		registry:  r,
+		symbols:   make(map[string]*Symbol),
+		external:  make(map[string]bool),
+	}
+	r.packages[name] = p
+	for _, n := range nicknames {
+		r.packages[n] = p
+	}
+	return p, nil
+}
+
+func (r *Registry) mustMakePackage(name string, nicknames ...string) *Package {
+	p, err := r.MakePackage(name, nicknames...)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// 名前かニックネームでパッケージを探す
+func (r *Registry) FindPackage(name string) (*Package, bool) {
+	p, ok := r.packages[name]
+	return p, ok
+}
+
+// otherの外部シンボルを、このパッケージから修飾子なしで使えるようにする
+func (p *Package) Use(others ...*Package) {
+	for _, other := range others {
+		if other == p || p.uses(other) {
+			continue
+		}
+		p.use = append(p.use, other)
+	}
+}
+
+func (p *Package) uses(other *Package) bool {
+	for _, u := range p.use {
+		if u == other {
+			return true
+		}
+	}
+	return false
+}
+
+// このパッケージから見える名前nameのシンボルを探す
+func (p *Package) FindSymbol(name string) (*Symbol, SymbolStatus) {
+	if sym, ok := p.symbols[name]; ok {
+		if p.external[name] {
+			return sym, SymbolExternal
+		}
+		return sym, SymbolInternal
+	}
+	for _, u := range p.use {
+		if sym, ok := u.symbols[name]; ok && u.external[name] {
+			return sym, SymbolInherited
+		}
+	}
+	return nil, SymbolNotFound
+}
+
+// このパッケージから見える名前nameのシンボルを返す
+// 見つからなければ、このパッケージに新しく作る
+// keywordパッケージのシンボルは、作ったときから外部シンボル
+func (p *Package) Intern(name string) *Symbol {
+	if sym, status := p.FindSymbol(name); status != SymbolNotFound {
+		return sym
+	}
+	sym := &Symbol{Name: name, Package: p}
+	p.symbols[name] = sym
+	if p == p.registry.KeywordPackage {
+		p.external[name] = true
+	}
+	return sym
+}
+
+// 名前nameのシンボルを外部シンボルにする
+// まだなければこのパッケージに作る
+func (p *Package) Export(name string) *Symbol {
+	sym, status := p.FindSymbol(name)
+	if status == SymbolNotFound || status == SymbolInherited {
+		// 継承しているシンボルは、このパッケージに取り込んでから公開する
+		if status == SymbolNotFound {
+			sym = &Symbol{Name: name, Package: p}
+		}
+		p.symbols[name] = sym
+	}
+	p.external[name] = true
+	return sym
+}
+
+func (p *Package) String() string {
+	return fmt.Sprintf("#<PACKAGE %s>", p.Name)
+}
+
+// このパッケージを登録した表
+func (p *Package) Registry() *Registry {
+	return p.registry
+}
+
+// common-lisp-userパッケージのシンボル
+// 読んだときと同じシンボルを、Goのコードから作るのに使う
+func (r *Registry) Intern(name string) *Symbol {
+	return r.CommonLispUser.Intern(name)
+}
+
+// :nameのキーワード
+func (r *Registry) Keyword(name string) *Symbol {
+	return r.KeywordPackage.Intern(name)
+}
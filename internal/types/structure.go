@@ -0,0 +1,77 @@
+package types
+
+import "strings"
+
+// defstructで定義した構造体の型
+// スロットは:includeした親の型のスロットを先頭に含む
+type StructType struct {
+	Name        *Symbol
+	Parent      *StructType // :includeした型、なければnil
+	Slots       []*StructSlot
+	Constructor *Symbol // キーワード引数で作るコンストラクタの名前、#S(...)で使う、なければnil
+}
+
+// 構造体のスロット
+type StructSlot struct {
+	Name     *Symbol
+	Default  Expr // 初期値の式、make-NAMEで値を渡されなかったときに評価する
+	ReadOnly bool // trueならsetfで書き換えられない
+}
+
+// 構造体のインスタンス
+// 値はStructType.Slotsと同じ順に並べる
+type Structure struct {
+	Type   *StructType
+	Values []Expr
+}
+
+// 構造体の型を登録する
+// 同じ名前の型がすでにあれば置き換える
+func (r *Registry) DefineStructType(t *StructType) {
+	r.structTypes[t.Name] = t
+}
+
+// 名前で構造体の型を探す
+func (r *Registry) FindStructType(name *Symbol) (*StructType, bool) {
+	t, ok := r.structTypes[name]
+	return t, ok
+}
+
+// tがotherか、otherを:includeした型かどうか
+func (t *StructType) IsSubtypeOf(other *StructType) bool {
+	for ; t != nil; t = t.Parent {
+		if t == other {
+			return true
+		}
+	}
+	return false
+}
+
+// 名前がnameのスロットの位置
+func (t *StructType) SlotIndex(name string) (int, bool) {
+	for i, slot := range t.Slots {
+		if slot.Name.Name == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func (t *StructType) String() string {
+	return "#<STRUCTURE-CLASS " + t.Name.String() + ">"
+}
+
+// #S(point :x 1 :y 2)
+func (s *Structure) String() string {
+	var sb strings.Builder
+	sb.WriteString("#S(")
+	sb.WriteString(s.Type.Name.String())
+	for i, slot := range s.Type.Slots {
+		sb.WriteString(" :")
+		sb.WriteString(slot.Name.Name)
+		sb.WriteString(" ")
+		sb.WriteString(s.Values[i].String())
+	}
+	sb.WriteString(")")
+	return sb.String()
+}
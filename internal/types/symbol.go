@@ -0,0 +1,90 @@
+package types
+
+import "reflect"
+
+// 属性リストからindicatorの値を探す
+func (s *Symbol) Get(indicator Expr) (Expr, bool) {
+	cell := s.plistCell(indicator)
+	if cell == nil {
+		return nil, false
+	}
+	return cell.Cdr.(*Cons).Car, true
+}
+
+// 属性リストのindicatorの値をvalueにする
+// まだなければ属性リストの先頭に加える
+func (s *Symbol) Put(indicator, value Expr) {
+	if cell := s.plistCell(indicator); cell != nil {
+		cell.Cdr.(*Cons).Car = value
+		return
+	}
+	plist := s.Plist
+	if plist == nil {
+		plist = &Nil{}
+	}
+	s.Plist = &Cons{Car: indicator, Cdr: &Cons{Car: value, Cdr: plist}}
+}
+
+// 属性リストからindicatorとその値を取り除く
+// 取り除いたときはtrue
+func (s *Symbol) Remove(indicator Expr) bool {
+	var prev *Cons
+	current := s.Plist
+	for {
+		cell, ok := current.(*Cons)
+		if !ok {
+			return false
+		}
+		valueCell, ok := cell.Cdr.(*Cons)
+		if !ok {
+			return false
+		}
+		if Eq(cell.Car, indicator) {
+			if prev == nil {
+				s.Plist = valueCell.Cdr
+			} else {
+				prev.Cdr = valueCell.Cdr
+			}
+			return true
+		}
+		prev = valueCell
+		current = valueCell.Cdr
+	}
+}
+
+// 属性リストの中で、Carがindicatorのセルを探す
+// 値はそのセルのCdrのCar
+func (s *Symbol) plistCell(indicator Expr) *Cons {
+	current := s.Plist
+	for {
+		cell, ok := current.(*Cons)
+		if !ok {
+			return nil
+		}
+		valueCell, ok := cell.Cdr.(*Cons)
+		if !ok {
+			return nil
+		}
+		if Eq(cell.Car, indicator) {
+			return cell
+		}
+		current = valueCell.Cdr
+	}
+}
+
+// Common Lispのeq
+// 同じオブジェクトかどうか
+// シンボルやコンスはポインタで比べる
+// NumberやStringは値なので、同じ値なら同じオブジェクトとみなす
+func Eq(a, b Expr) bool {
+	if _, ok := a.(*Nil); ok {
+		_, ok := b.(*Nil)
+		return ok
+	}
+	// 多値のように比べられない値は、同じ型でも同じオブジェクトとはみなせない
+	// 関数はポインタなので、同じ関数ならeq
+	if t := reflect.TypeOf(a); t != reflect.TypeOf(b) || !t.Comparable() {
+		return false
+	}
+	return a == b
+}
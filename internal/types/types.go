@@ -9,12 +9,17 @@ type Expr interface {
 	String() string
 }
 
-type Number struct {
-	Value float64
-}
-
+// シンボル
+// パッケージにインターンされるので、同じパッケージの同じ名前は常に同じ*Symbolになる
+// そのため、シンボルどうしはポインタで比較できる
 type Symbol struct {
-	Name string
+	Name     string
+	Package  *Package // シンボルが属するパッケージ、nilならどこにも属さない
+	Value    Expr     // 値セル、グローバルな値、nilなら未束縛
+	Function Expr     // 関数セル、nilなら未束縛
+	Plist    Expr     // 属性リスト (indicator1 value1 indicator2 value2 ...)、nilなら空
+	Special  bool     // defvarなどでスペシャル変数と宣言されていれば、束縛は動的になる
+	Constant bool     // defconstantで定義した定数なら、値を変えられない
 }
 
 type Nil struct{}
@@ -32,26 +37,57 @@ type Cons struct {
 	Cdr Expr
 }
 
-func (n Number) String() string {
-	// 整数なら%d表示
-	if n.Value == float64(int64(n.Value)) {
-		return fmt.Sprintf("%d", int64(n.Value))
-	}
-
-	return fmt.Sprintf("%g", n.Value) //小数点以下があるときは少数形式、それ以外は整数形式
-}
-
 func (n Nil) String() string {
 	return "NIL"
 }
 
-func (s Symbol) String() string {
-	return s.Name
+// キーワードは:name、common-lisp-userから見えないパッケージのシンボルはpkg::nameと表示する
+func (s *Symbol) String() string {
+	if s.Package == nil {
+		return s.Name
+	}
+	switch r := s.Package.registry; s.Package {
+	case r.CommonLisp, r.CommonLispUser:
+		return s.Name
+	case r.KeywordPackage:
+		return ":" + s.Name
+	}
+	if _, status := s.Package.FindSymbol(s.Name); status == SymbolExternal {
+		return s.Package.Name + ":" + s.Name
+	}
+	return s.Package.Name + "::" + s.Name
+}
+
+// :testのようなキーワードかどうか
+func (s *Symbol) IsKeyword() bool {
+	return s.Package != nil && s.Package == s.Package.registry.KeywordPackage
 }
 
 // 文字列は""をつける
+// 読み直したときに同じ文字列になるように、"や\や制御文字はエスケープする
 func (s String) String() string {
-	return fmt.Sprintf("\"%s\"", s.Value)
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range s.Value {
+		switch r {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\t':
+			sb.WriteString(`\t`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&sb, `\u%04x`, r)
+			} else {
+				sb.WriteRune(r)
+			}
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
 }
 
 func (b Boolean) String() string {
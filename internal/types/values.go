@@ -0,0 +1,50 @@
+package types
+
+import "strings"
+
+// 多値
+// (values 1 2)のように、0個か2個以上の値を返すときに使う
+// 関数の引数のような普通の位置では最初の値だけを使い、残りは捨てる
+type MultipleValues struct {
+	Values []Expr
+}
+
+// REPLと同じく、値を1行に1つずつ並べる
+func (m MultipleValues) String() string {
+	values := make([]string, len(m.Values))
+	for i, value := range m.Values {
+		values[i] = value.String()
+	}
+	return strings.Join(values, "\n")
+}
+
+// 最初の値
+// 多値でなければその値のまま、値が1つもなければNIL
+func PrimaryValue(expr Expr) Expr {
+	m, ok := expr.(MultipleValues)
+	if !ok {
+		return expr
+	}
+	if len(m.Values) == 0 {
+		return &Nil{}
+	}
+	return m.Values[0]
+}
+
+// すべての値
+// 多値でなければその値1つだけ
+func ValueList(expr Expr) []Expr {
+	if m, ok := expr.(MultipleValues); ok {
+		return m.Values
+	}
+	return []Expr{expr}
+}
+
+// valuesを多値にする
+// 値が1つなら多値にせず、その値をそのまま返す
+func NewValues(values ...Expr) Expr {
+	if len(values) == 1 {
+		return values[0]
+	}
+	return MultipleValues{Values: values}
+}